@@ -4,13 +4,15 @@ import (
 	"context"
 	"fmt"
 	"golinq/gl"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 )
 
 // Listens on a channel until the channel is closed or a timeout threshold is reached
 // and return the number of elements received, or -1 if timed out.
-func countOrTimeOut[T any](source chan T, timeoutSec int) int {
+func countOrTimeOut[T any](source <-chan T, timeoutSec int) int {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeoutSec)*time.Second)
 	defer cancel()
 	ch := make(chan int)
@@ -19,14 +21,15 @@ func countOrTimeOut[T any](source chan T, timeoutSec int) int {
 	case res := <-ch:
 		return res // counted successfully
 	case <-ctx.Done():
-		return -1 // timed out
+		gl.Stop(source) // stop the source so the counting goroutine can finish
+		return -1       // timed out
 	}
 }
 
 // Given a channel of integers,
 // return a string holding those integers, separated
 // by the given separator
-func concatInts(separator string, source chan int) string {
+func concatInts(separator string, source <-chan int) string {
 	if source == nil {
 		return ""
 	}
@@ -48,7 +51,7 @@ func concatInts(separator string, source chan int) string {
 // Given a channel of float64s,
 // return a string holding those float64s, separated
 // by the given separator
-func concatFloats(separator string, source chan float64) string {
+func concatFloats(separator string, source <-chan float64) string {
 	if source == nil {
 		return ""
 	}
@@ -89,6 +92,16 @@ func main() {
 	first := gl.First(gl.From(ints))
 	fmt.Println(first) // prints "1"
 
+	fmt.Println("Last even int, and whether there is any int greater than 100:")
+	lastEven, _ := gl.LastOkFunc(gl.From(ints), isEven)
+	_, anyGreaterThan100 := gl.FirstOkFunc(gl.From(ints), func(i int) bool { return i > 100 })
+	fmt.Println(lastEven, anyGreaterThan100) // prints "8 false"
+
+	fmt.Println("Fifth int, and whether there is a twentieth:")
+	fifth, _ := gl.ElementAt(gl.From(ints), 4)
+	_, hasTwentieth := gl.ElementAt(gl.From(ints), 19)
+	fmt.Println(fifth, hasTwentieth) // prints "4 false"
+
 	fmt.Println("Last int:")
 	last := gl.Last(gl.From(ints))
 	fmt.Println(last) // prints "8"
@@ -97,18 +110,151 @@ func main() {
 	count := gl.Count(gl.From(ints))
 	fmt.Println(count) // prints "9"
 
+	fmt.Println("Count of even ints:")
+	evenCount := gl.CountIf(gl.From(ints), isEven)
+	fmt.Println(evenCount) // prints "4"
+
+	fmt.Println("Given ints grouped by parity and then by size:")
+	parity := func(i int) string {
+		if isEven(i) {
+			return "even"
+		}
+		return "odd"
+	}
+	size := func(i int) string {
+		if i > 5 {
+			return "large"
+		}
+		return "small"
+	}
+	groups := gl.GroupByMulti(gl.From(ints), []func(int) string{parity, size})
+	fmt.Println(groups.Get("odd", "small").Items, groups.Get("even").Count()) // prints "[1 3 1 5] 4"
+
 	fmt.Println("Sum of ints:")
 	sum := gl.Sum(gl.From(ints))
 	fmt.Println(sum) // prints "39"
 
+	fmt.Println("Are the squares of the given ints the same as the products of each int with itself?")
+	sameAsSquares := gl.SequenceEqual(gl.Map(gl.From(ints), square), gl.Zip(gl.From(ints), gl.From(ints), func(a int, b int) int { return a * b }))
+	fmt.Println(sameAsSquares) // prints "true"
+
+	fmt.Println("Union, intersection, and difference of the given ints with the first ten Fibonacci numbers:")
+	fmt.Println(concatInts(", ", gl.Union(gl.From(ints), gl.Take(gl.Fibonaccis(), 10))))     // prints "1, 2, 3, 6, 4, 9, 5, 8, 13, 21, 34, 55"
+	fmt.Println(concatInts(", ", gl.Intersect(gl.From(ints), gl.Take(gl.Fibonaccis(), 10)))) // prints "1, 2, 3, 5, 8"
+	fmt.Println(concatInts(", ", gl.Except(gl.From(ints), gl.Take(gl.Fibonaccis(), 10))))    // prints "6, 4, 9"
+
+	fmt.Println("Running totals of ints:")
+	add := func(a int, b int) int { return a + b }
+	fmt.Println(concatInts(", ", gl.Scan(gl.From(ints), 0, add))) // prints "1, 3, 6, 12, 16, 17, 26, 31, 39"
+
+	fmt.Println("Sum and average of ints, in batches of four:")
+	batchSum := gl.SumBatches(gl.Batch(gl.From(ints), 4))
+	batchAverage, _ := gl.AverageBatches(gl.Batch(gl.From(ints), 4))
+	fmt.Printf("%d %.6f\n", batchSum, batchAverage) // prints "39 4.333333"
+
+	fmt.Println("Sum of numbers parsed from strings, stopping at the first that does not parse:")
+	parsedSum, parseErr := gl.TryReduce(gl.TryMap(gl.Try(gl.From([]string{"1", "2", "x", "4"})), strconv.Atoi), 0, func(a int, b int) (int, error) { return a + b, nil })
+	fmt.Println(parsedSum, parseErr != nil) // prints "3 true"
+
+	fmt.Println("Squares of given ints, computed on four workers:")
+	fmt.Println(concatInts(", ", gl.ParallelMap(gl.From(ints), square, 4))) // prints "1, 4, 9, 36, 16, 1, 81, 25, 64"
+
+	fmt.Println("Sum of the even squares of given ints, computed on four workers in no particular order:")
+	fmt.Println(gl.Sum(gl.ParallelFilterUnordered(gl.ParallelMapUnordered(gl.From(ints), square, 4), isEven, 4))) // prints "120"
+
+	fmt.Println("Sum of squares of ints from zero to 999, with a buffer of 128 between stages:")
+	fmt.Println(gl.Sum(gl.Map(gl.Range(0, 1000, gl.WithBuffer(128)), square, gl.WithBuffer(128)))) // prints "332833500"
+
+	fmt.Println("Min of given ints, and whether ints greater than 100 have a max, a sum, or an average:")
+	min := gl.Min(gl.From(ints))
+	_, hasMax := gl.MaxOk(gl.Filter(gl.From(ints), func(i int) bool { return i > 100 }))
+	_, hasSum := gl.SumOk(gl.Filter(gl.From(ints), func(i int) bool { return i > 100 }))
+	_, hasAverage := gl.AverageOk(gl.Filter(gl.From(ints), func(i int) bool { return i > 100 }))
+	fmt.Println(min, hasMax, hasSum, hasAverage) // prints "1 false false false"
+
+	fmt.Println("First three even squares of given ints, fused onto one goroutine:")
+	fmt.Println(gl.Fuse(gl.From(ints)).Map(square).Filter(isEven).Take(3).ToSlice()) // prints "[4 36 16]"
+
+	fmt.Println("Count, mean, standard deviation, and median of given ints:")
+	summary := gl.Summarize(gl.From(ints))
+	fmt.Printf("%d %.6f %.6f %.0f\n", summary.Count, summary.Mean, summary.StdDev, summary.P50) // prints "9 4.333333 2.748737 4"
+
+	fmt.Println("Sum of first three ints, noting each one on the way:")
+	var noted []int
+	tapped := gl.Sum(gl.Tap(gl.Take(gl.From(ints), 3), func(i int) { noted = append(noted, i) }))
+	fmt.Println(tapped, noted) // prints "6 [1 2 3]"
+
+	fmt.Println("Squares of given ints, dropping those whose request was cancelled (the multiples of three):")
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	requestOf := func(i int) context.Context {
+		if i%3 == 0 {
+			return cancelled
+		}
+		return context.Background()
+	}
+	squareCtx := func(_ context.Context, i int) int { return square(i) }
+	fmt.Println(concatInts(", ", gl.DetachCtx(gl.MapWithCtx(gl.AttachCtx(gl.From(ints), requestOf), squareCtx)))) // prints "1, 4, 16, 1, 25, 64"
+
+	fmt.Println("Given ints, at most three every ten milliseconds, and whether that took at least twenty milliseconds:")
+	throttleStart := time.Now()
+	fmt.Println(concatInts(", ", gl.Throttle(gl.From(ints), 3, 10*time.Millisecond)), time.Since(throttleStart) >= 20*time.Millisecond) // prints "1, 2, 3, 6, 4, 1, 9, 5, 8 true"
+
+	fmt.Println("Last of a burst of ints, once the burst has been quiet for ten milliseconds:")
+	fmt.Println(concatInts(", ", gl.Debounce(gl.From(ints), 10*time.Millisecond))) // prints "8"
+
+	fmt.Println("Distinct given ints, and the sums of given ints by parity, folded on four shards:")
+	sumsByParity := gl.FoldByKeySharded(gl.From(ints), parity, 0, add, 4)
+	fmt.Println(concatInts(", ", gl.Distinct(gl.From(ints))), sumsByParity["even"], sumsByParity["odd"]) // prints "1, 2, 3, 6, 4, 9, 5, 8 20 19"
+
+	fmt.Println("Most recent of given ints, sampled every ten milliseconds:")
+	fmt.Println(concatInts(", ", gl.Sample(gl.From(ints), 10*time.Millisecond))) // prints "8"
+
+	fmt.Println("Given ints, in snapshots cut every ten milliseconds:")
+	fmt.Println(gl.ToSlice(gl.SnapshotEvery(gl.From(ints), 10*time.Millisecond))) // prints "[[1 2 3 6 4 1 9 5 8]]"
+
+	fmt.Println("Given ints at three per hour, until none arrives for twenty milliseconds, and whether that timed out:")
+	timed, timedOut := gl.ToSliceOrError(gl.Timeout(gl.Throttle(gl.From(ints), 3, time.Hour), 20*time.Millisecond))
+	fmt.Println(timed, timedOut == gl.ErrTimeout) // prints "[1 2 3] true"
+
+	fmt.Println("First five ticks of a five-millisecond interval, and whether a ten-millisecond timer fires once:")
+	fmt.Println(concatInts(", ", gl.Take(gl.Interval(5*time.Millisecond), 5)), gl.Count(gl.Timer(10*time.Millisecond)) == 1) // prints "0, 1, 2, 3, 4 true"
+
+	fmt.Println("Given ints, split into runs that rise:")
+	rises := func(run []int, i int) ([]int, [][]int) {
+		if len(run) > 0 && i <= run[len(run)-1] {
+			return []int{i}, [][]int{run}
+		}
+		return append(run, i), nil
+	}
+	lastRun := func(run []int) [][]int { return [][]int{run} }
+	fmt.Println(gl.ToSlice(gl.StateMachineFinal(gl.From(ints), nil, rises, lastRun))) // prints "[[1 2 3 6] [4] [1 9] [5 8]]"
+
+	fmt.Println("Count, sum, and max of the even squares of given ints, computed once:")
+	evenSquares := gl.Memoize(func() <-chan int { return gl.Filter(gl.Map(gl.From(ints), square), isEven) })
+	fmt.Println(gl.Count(evenSquares()), gl.Sum(evenSquares()), gl.Max(evenSquares())) // prints "4 120 64"
+
 	fmt.Println("Sum of first three ints:")
 	sum3 := gl.Sum(gl.Take(gl.From(ints), 3))
 	fmt.Println(sum3) // prints "6"
 
 	fmt.Println("Sum of final two ints:")
-	sumFinal2 := gl.Sum(gl.Skip(gl.From(ints), count-2))
+	sumFinal2 := gl.Sum(gl.TakeLast(gl.From(ints), 2))
 	fmt.Println(sumFinal2) // prints "13"
 
+	fmt.Println("All but the final two ints:")
+	fmt.Println(concatInts(", ", gl.SkipLast(gl.From(ints), 2))) // prints "1, 2, 3, 6, 4, 1, 9"
+
+	fmt.Println("Given ints, in reverse order:")
+	fmt.Println(concatInts(", ", gl.Reverse(gl.From(ints)))) // prints "8, 5, 9, 1, 4, 6, 3, 2, 1"
+
+	fmt.Println("Given ints, surrounded by zeros:")
+	fmt.Println(concatInts(", ", gl.Append(gl.Prepend(gl.From(ints), 0), 0))) // prints "0, 1, 2, 3, 6, 4, 1, 9, 5, 8, 0"
+
+	fmt.Println("Given ints greater than 100, or -1 if there are none:")
+	greaterThan100 := func(i int) bool { return i > 100 }
+	fmt.Println(concatInts(", ", gl.DefaultIfEmpty(gl.Filter(gl.From(ints), greaterThan100), -1))) // prints "-1"
+
 	fmt.Println("First ten Fibonacci numbers")
 	first10Fibs := gl.Take(gl.Fibonaccis(), 10)
 	fmt.Println(concatInts(", ", first10Fibs)) // prints "1, 1, 2, 3, 5, 8, 13, 21, 34, 55"
@@ -118,6 +264,14 @@ func main() {
 	first10FibsIgnoreFirstFour := gl.Take(gl.Skip(gl.Fibonaccis(), 4), 10)
 	fmt.Println(concatInts(", ", first10FibsIgnoreFirstFour)) // prints "5, 8, 13, 21, 34, 55, 89, 144, 233, 377"
 
+	fmt.Println("Fibonacci numbers less than 10000:")
+	lessThan10000 := func(i int) bool { return i < 10000 }
+	fmt.Println(concatInts(", ", gl.TakeWhile(gl.Fibonaccis(), lessThan10000))) // prints "1, 1, 2, 3, 5, ..., 4181, 6765"
+
+	fmt.Println("Given ints, starting from the first int greater than 5:")
+	atMostFive := func(i int) bool { return i <= 5 }
+	fmt.Println(concatInts(", ", gl.SkipWhile(gl.From(ints), atMostFive))) // prints "6, 4, 1, 9, 5, 8"
+
 	fmt.Println("Squares of first ten Fibonacci numbers")
 	squareFirst10Fibs := gl.Take(gl.Map(gl.Fibonaccis(), square), 10) // Note: mapping before taking. Can we call map on an unending stream of data?
 	fmt.Println(concatInts(", ", squareFirst10Fibs))                  // Indeed we can; this line prints "1, 1, 4, 9, 25, 64, 169, 441, 1156, 3025"
@@ -130,17 +284,34 @@ func main() {
 		fmt.Println("Timed out, obviously") // Times out, obviously
 	}
 
-	fmt.Println("Multiply each integer in the test set by the subsequent integer:")
+	fmt.Println("Fibonacci numbers received within fifty milliseconds:")
+	fibsBeforeDeadline := gl.TakeUntil(gl.Fibonaccis(), time.After(50*time.Millisecond))
+	fmt.Println(gl.Count(fibsBeforeDeadline) > 0) // prints "true"
+
 	product := func(a int, b int) int { return a * b }
+	fmt.Println("Given ints, each multiplied by its index:")
+	fmt.Println(concatInts(", ", gl.Zip(gl.From(ints), gl.Range(0, len(ints)), product))) // prints "0, 2, 6, 18, 16, 5, 54, 35, 64"
+
+	fmt.Println("Sum of ten sevens:")
+	fmt.Println(gl.Sum(gl.Repeat(7, 10))) // prints "70"
+
+	fmt.Println("First five powers of two:")
+	double := func(i int) (int, int, bool) { return i, 2 * i, true }
+	fmt.Println(concatInts(", ", gl.Take(gl.Generate(1, double), 5))) // prints "1, 2, 4, 8, 16"
+
+	fmt.Println("First three even squares of given ints, as a query:")
+	fmt.Println(gl.QueryFrom(ints).Map(square).Filter(isEven).Take(3).ToSlice()) // prints "[4 36 16]"
+
+	fmt.Println("Given ints, sorted with the slices package by way of iterators:")
+	fmt.Println(concatInts(", ", gl.FromSeq(slices.Values(slices.Sorted(gl.ToSeq(gl.From(ints))))))) // prints "1, 1, 2, 3, 4, 5, 6, 8, 9"
+
+	fmt.Println("Multiply each integer in the test set by the subsequent integer:")
 	offsetProducts := gl.Zip(gl.From(ints), gl.Skip(gl.From(ints), 1), product)
 	fmt.Println(concatInts(", ", offsetProducts)) // prints "2, 6, 18, 24, 4, 9, 45, 40"
 
 	fmt.Println("Successive ratios of the five Fibonacci numbers after skipping the first five:")
 	ratio := func(a int, b int) float64 { return float64(b) / float64(a) }
-	fibs := gl.Fibonaccis()
-	fibs2 := gl.Skip(gl.Fibonaccis(), 1)
-	phiApproximations := gl.Take(gl.Skip(gl.Zip(fibs, fibs2, ratio), 5), 5)
+	fibs := gl.Replay(gl.Fibonaccis(), 0) // one generator, subscribed to twice
+	phiApproximations := gl.Take(gl.Skip(gl.Zip(fibs.Subscribe(), gl.Skip(fibs.Subscribe(), 1), ratio), 5), 5)
 	fmt.Println(concatFloats(", ", phiApproximations)) // prints "1.625000, 1.615385, 1.619048, 1.617647, 1.618182"
-	close(fibs)
-	close(fibs2)
 }
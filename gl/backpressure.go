@@ -0,0 +1,101 @@
+package gl
+
+import "context"
+
+// What OnBackpressureBuffer does with a new value when its buffer is full
+type BackpressureStrategy int
+
+const (
+	// The producer waits until the consumer makes room
+	BackpressureBlock BackpressureStrategy = iota
+	// The new value is dropped
+	BackpressureDropNewest
+	// The oldest buffered value is dropped to make room for the new one
+	BackpressureDropOldest
+)
+
+// Eagerly receives values from a channel and sends them on a new channel,
+// buffering up to n = capacity of them while the consumer is slower than the producer.
+// When the buffer is full, the given strategy decides whether the producer waits
+// or a value is dropped; onDrop, if not nil, is called with each value dropped.
+// A capacity of zero or less is treated as one.
+// Once the source closes, the buffered values are still sent.
+// With WithMemoryBudget, the buffered values are held within the budget,
+// and exceeding it ends the stream whatever the strategy.
+func OnBackpressureBuffer[T any](source <-chan T, capacity int, strategy BackpressureStrategy, onDrop func(T), options ...Option) <-chan T {
+	if source == nil {
+		return nil
+	}
+	config := configure(options)
+	output, ctx, done := newConfiguredStage[T](context.Background(), config, source)
+	go func() {
+		defer done()
+		memory := config.memoryAccount()
+		defer memory.releaseAll()
+		buffer := newRing[T](max(capacity, 1))
+		input := source
+		for input != nil || buffer.size > 0 {
+			// Stop receiving while full only if the producer is to wait
+			receiving := input
+			if _, full := buffer.oldestIfFull(); full && strategy == BackpressureBlock {
+				receiving = nil
+			}
+			var sending chan T
+			var next T
+			if buffer.size > 0 {
+				sending = output
+				next = buffer.buf[buffer.start]
+			}
+			select {
+			case s, more := <-receiving:
+				if !more {
+					input = nil
+					continue
+				}
+				if _, full := buffer.oldestIfFull(); full && strategy == BackpressureDropNewest {
+					if onDrop != nil {
+						onDrop(s)
+					}
+					continue
+				}
+				if !memory.hold(s) {
+					return
+				}
+				if evicted, dropped := buffer.push(s); dropped {
+					memory.release(evicted)
+					if onDrop != nil {
+						onDrop(evicted)
+					}
+				}
+			case sending <- next:
+				buffer.pop()
+				memory.release(next)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// Forwards the values received on a channel, holding at most one
+// while the consumer is busy and dropping any that arrive meanwhile,
+// so that a real-time producer is never held up by a slow consumer
+func OnBackpressureDrop[T any](source <-chan T) <-chan T {
+	return OnBackpressureBuffer(source, 1, BackpressureDropNewest, nil)
+}
+
+// Forwards the values received on a channel, keeping only the latest one
+// while the consumer is busy, so that the consumer always gets the freshest value
+// and a real-time producer is never held up
+func OnBackpressureLatest[T any](source <-chan T) <-chan T {
+	return OnBackpressureBuffer(source, 1, BackpressureDropOldest, nil)
+}
+
+// Eagerly receives up to n values ahead of the consumer into a buffer
+// and sends them on a new channel, so that a source with bursty latency
+// (such as network reads) keeps a steady consumer busy.
+// An n of zero or less is treated as one.
+func Prefetch[T any](source <-chan T, n int, options ...Option) <-chan T {
+	return OnBackpressureBuffer(source, n, BackpressureBlock, nil, options...)
+}
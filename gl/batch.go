@@ -0,0 +1,214 @@
+package gl
+
+import (
+	"context"
+	"time"
+)
+
+// Settings for AdaptiveBatch.
+// Zero fields take the defaults noted beside them.
+type AdaptiveBatchConfig struct {
+	MinSize       int           // smallest batch size; default 1
+	MaxSize       int           // largest batch size; default 1024
+	TargetLatency time.Duration // sink latency above which batches shrink; required
+	Increase      int           // added to the batch size after a fast batch; default 1
+	Decrease      float64       // batch size multiplier after a slow batch; default 0.5
+	MaxWait       time.Duration // longest to wait while filling a batch; default no limit
+}
+
+func (c AdaptiveBatchConfig) withDefaults() AdaptiveBatchConfig {
+	if c.MinSize <= 0 {
+		c.MinSize = 1
+	}
+	if c.MaxSize <= 0 {
+		c.MaxSize = 1024
+	}
+	c.MaxSize = max(c.MaxSize, c.MinSize)
+	if c.Increase <= 0 {
+		c.Increase = 1
+	}
+	if c.Decrease <= 0 || c.Decrease >= 1 {
+		c.Decrease = 0.5
+	}
+	return c
+}
+
+// Receives values from a channel in batches and passes each batch to the given sink,
+// tuning the batch size as it goes: the size grows additively while the sink
+// handles batches within the target latency and shrinks multiplicatively when it does not.
+// Returns once the channel closes and the final batch has been handled.
+// Respects WithMemoryBudget: if a batch cannot be held within the budget,
+// the channel is stopped and AdaptiveBatch returns without handling it.
+func AdaptiveBatch[T any](source <-chan T, config AdaptiveBatchConfig, sink func([]T), options ...Option) {
+	config = config.withDefaults()
+	memory := configure(options).memoryAccount()
+	defer memory.releaseAll()
+	size := config.MinSize
+	for {
+		batch, more, held := collectBatch(source, size, config.MaxWait, memory)
+		if !held {
+			Stop(source)
+			return
+		}
+		if len(batch) > 0 {
+			start := time.Now()
+			sink(batch)
+			releaseEach(memory, batch)
+			if time.Since(start) <= config.TargetLatency {
+				size = min(size+config.Increase, config.MaxSize)
+			} else {
+				size = max(int(float64(size)*config.Decrease), config.MinSize)
+			}
+		}
+		if !more {
+			return
+		}
+	}
+}
+
+// Receives up to size values from a channel, waiting at most maxWait
+// after the first one if maxWait is positive.
+// Also reports whether the channel may have more values,
+// and false if the batch could not be held within the given memory account.
+func collectBatch[T any](source <-chan T, size int, maxWait time.Duration, memory *memoryAccount) ([]T, bool, bool) {
+	batch := make([]T, 0, size)
+	var deadline <-chan time.Time
+	for len(batch) < size {
+		select {
+		case s, more := <-source:
+			if !more {
+				return batch, false, true
+			}
+			if !memory.hold(s) {
+				return nil, false, false
+			}
+			batch = append(batch, s)
+			if deadline == nil && maxWait > 0 {
+				deadline = time.After(maxWait)
+			}
+		case <-deadline:
+			return batch, true, true
+		}
+	}
+	return batch, true, true
+}
+
+// Receives values from a channel and sends them on a new channel
+// in batches of n = size values; the final batch may be smaller.
+// Batching lets downstream stages work on slices with plain loops
+// instead of paying for a channel receive per value.
+// Respects WithMemoryBudget, holding each batch's values until the batch is sent.
+func Batch[T any](source <-chan T, size int, options ...Option) <-chan []T {
+	if source == nil {
+		return nil
+	}
+	size = max(size, 1)
+	config := configure(options)
+	output, ctx, done := newConfiguredStage[[]T](context.Background(), config, source)
+	go func() {
+		defer done()
+		memory := config.memoryAccount()
+		defer memory.releaseAll()
+		for {
+			batch, more, held := collectBatch(source, size, 0, memory)
+			if !held {
+				return
+			}
+			if len(batch) > 0 {
+				if !send(ctx, output, batch) {
+					return
+				}
+				releaseEach(memory, batch)
+			}
+			if !more {
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// Receives every value from a channel of batches and sends them one at a time
+func Unbatch[T any](source <-chan []T) <-chan T {
+	if source == nil {
+		return nil
+	}
+	output, ctx, done := newStage[T](context.Background(), source)
+	go func() {
+		defer done()
+		for batch := range source {
+			for _, s := range batch {
+				if !send(ctx, output, s) {
+					return
+				}
+			}
+		}
+	}()
+	return output
+}
+
+// Batch-mode aggregations.
+// These loop over each batch directly, which the compiler can optimize
+// far better than a receive per value.
+// They are opt-in: Sum, Min, Max and AverageOk still receive one value at a time,
+// since a channel of single values cannot be read a batch at a time.
+
+// Given a channel of batches of numeric values, return the Sum of all the values
+func SumBatches[T Number](source <-chan []T) T {
+	var total T
+	for batch := range source {
+		for _, s := range batch {
+			total += s
+		}
+	}
+	return total
+}
+
+// Given a channel of batches of numeric values, return the smallest value,
+// or the zero value and false if there are no values
+func MinBatches[T Number](source <-chan []T) (T, bool) {
+	var least T
+	found := false
+	for batch := range source {
+		for _, s := range batch {
+			if !found || s < least {
+				least = s
+				found = true
+			}
+		}
+	}
+	return least, found
+}
+
+// Given a channel of batches of numeric values, return the largest value,
+// or the zero value and false if there are no values
+func MaxBatches[T Number](source <-chan []T) (T, bool) {
+	var greatest T
+	found := false
+	for batch := range source {
+		for _, s := range batch {
+			if !found || s > greatest {
+				greatest = s
+				found = true
+			}
+		}
+	}
+	return greatest, found
+}
+
+// Given a channel of batches of numeric values, return the mean of all the values,
+// or zero and false if there are no values
+func AverageBatches[T Number](source <-chan []T) (float64, bool) {
+	var total float64
+	count := 0
+	for batch := range source {
+		for _, s := range batch {
+			total += float64(s)
+		}
+		count += len(batch)
+	}
+	if count == 0 {
+		return 0, false
+	}
+	return total / float64(count), true
+}
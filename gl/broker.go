@@ -0,0 +1,173 @@
+package gl
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Message brokers.
+// Broker clients (Kafka, NATS, AMQP, ...) are not dependencies of this package;
+// instead, a consumer is wrapped as a Source and a producer as a Sink,
+// and their messages flow through a pipeline as Messages that remember
+// how to acknowledge them. MapMessages and FilterMessages carry the acknowledgement
+// along with the value, and ForEachMessage and ToSink settle each message
+// once it has been processed: ack on success, nack on error.
+
+// A consumer of messages from a broker
+type Source[T any] interface {
+	// Blocks until the next message arrives and returns it,
+	// or returns an error if ctx is done or the consumer fails
+	Receive(ctx context.Context) (Message[T], error)
+	// Releases the consumer; messages not yet acknowledged are redelivered by the broker
+	Close() error
+}
+
+// A producer of messages to a broker
+type Sink[T any] interface {
+	// Publishes a value, returning once the broker has accepted it
+	Send(ctx context.Context, value T) error
+}
+
+// A value received from a broker, along with how to settle it
+type Message[T any] struct {
+	Value  T
+	settle *settlement
+}
+
+// How to settle one message, once only
+type settlement struct {
+	once sync.Once
+	ack  func() error
+	nack func(error) error
+	err  error
+}
+
+// Creates a message with the given value, to be settled with the given functions.
+// Either may be nil if the broker has nothing to do for it.
+func NewMessage[T any](value T, ack func() error, nack func(reason error) error) Message[T] {
+	return Message[T]{Value: value, settle: &settlement{ack: ack, nack: nack}}
+}
+
+// Acknowledges that the message has been processed.
+// Only the first call to Ack or Nack has any effect; later calls return its error.
+func (m Message[T]) Ack() error {
+	if m.settle == nil {
+		return nil
+	}
+	m.settle.once.Do(func() {
+		if m.settle.ack != nil {
+			m.settle.err = m.settle.ack()
+		}
+	})
+	return m.settle.err
+}
+
+// Reports that the message could not be processed, for the given reason,
+// so that the broker can redeliver or dead-letter it.
+// Only the first call to Ack or Nack has any effect; later calls return its error.
+func (m Message[T]) Nack(reason error) error {
+	if m.settle == nil {
+		return nil
+	}
+	m.settle.once.Do(func() {
+		if m.settle.nack != nil {
+			m.settle.err = m.settle.nack(reason)
+		}
+	})
+	return m.settle.err
+}
+
+// Receives messages from the given source and sends them on a new channel,
+// closing the source once the channel is stopped or the source fails.
+// If Receive fails, onError (if not nil) is called with the error
+// and the channel closes.
+func FromSource[T any](source Source[T], onError func(error), options ...Option) <-chan Message[T] {
+	return FromSourceCtx(context.Background(), source, onError, options...)
+}
+
+// Context-aware FromSource
+func FromSourceCtx[T any](ctx context.Context, source Source[T], onError func(error), options ...Option) <-chan Message[T] {
+	output, ctx, done := newConfiguredStage[Message[T]](ctx, configure(options))
+	go func() {
+		defer done()
+		defer source.Close()
+		for {
+			m, err := source.Receive(ctx)
+			if err != nil {
+				if ctx.Err() == nil && onError != nil {
+					onError(err)
+				}
+				return
+			}
+			if !send(ctx, output, m) {
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// Applies the given fallible mapper to the value of each message received on a channel
+// and sends the result on a new channel as a message that settles the original one.
+// If the mapper fails, the message is nacked with its error and dropped.
+func MapMessages[T1 any, T2 any](source <-chan Message[T1], mapper func(T1) (T2, error), options ...Option) <-chan Message[T2] {
+	return Unwrap(Map(source, func(m Message[T1]) Result[Message[T2]] {
+		value, err := mapper(m.Value)
+		if err != nil {
+			m.Nack(err)
+			return Fail[Message[T2]](err)
+		}
+		return Ok(Message[T2]{Value: value, settle: m.settle})
+	}, options...), nil)
+}
+
+// Sends the messages received on a channel whose values satisfy the given predicate
+// on a new channel. The others need no more processing, so they are acked and dropped.
+func FilterMessages[T any](source <-chan Message[T], predicate func(T) bool, options ...Option) <-chan Message[T] {
+	return Filter(source, func(m Message[T]) bool {
+		if predicate(m.Value) {
+			return true
+		}
+		m.Ack()
+		return false
+	}, options...)
+}
+
+// Listens on a channel of messages until it is closed and processes each value,
+// acking its message if process succeeds and nacking it with the error otherwise.
+// If a message cannot be settled (for instance, the connection to the broker is lost),
+// the channel is stopped and that error returned.
+func ForEachMessage[T any](source <-chan Message[T], process func(T) error) error {
+	for m := range source {
+		var err error
+		if failure := process(m.Value); failure != nil {
+			err = m.Nack(failure)
+		} else {
+			err = m.Ack()
+		}
+		if err != nil {
+			Stop(source)
+			return err
+		}
+	}
+	return nil
+}
+
+// Listens on a channel of messages until it is closed and publishes each value to the given sink,
+// acking its message once the sink has accepted it and nacking it if the sink fails.
+// Returns nil once the channel closes, or ctx.Err() if ctx is done first
+// (in which case the channel is stopped, and the messages still in it are left unsettled),
+// or, if a message cannot be settled, stops the channel and returns that error.
+func ToSink[T any](ctx context.Context, source <-chan Message[T], sink Sink[T]) error {
+	err := ForEachMessage(TakeUntil(source, ctx.Done()), func(value T) error {
+		if err := sink.Send(ctx, value); err != nil {
+			return errors.Join(err, ctx.Err())
+		}
+		return nil
+	})
+	if err == nil {
+		err = ctx.Err()
+	}
+	return err
+}
@@ -0,0 +1,139 @@
+package gl
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+)
+
+// Recorded by a MemoryBudget once a buffering operator has exceeded it
+var ErrMemoryBudgetExceeded = errors.New("gl: memory budget exceeded")
+
+// A MemoryBudget limits how much memory the buffering operators of a pipeline
+// (Reverse, TakeLast, SkipLast, Batch, SnapshotEvery, GroupByMulti, OnBackpressureBuffer,
+// Replay, Resequence, PriorityBuffer, CollectSortedWithin) may hold at once.
+// Give each of them WithMemoryBudget with the same budget to share one limit across the pipeline.
+// When an operator cannot reserve memory for another element,
+// it stops its source, closes its output early, and the budget records
+// ErrMemoryBudgetExceeded, rather than growing until the process runs out of memory.
+// Nothing is spilled to disk: the elements of a channel may be of any type,
+// including ones that cannot be written out and read back (funcs, channels,
+// pointers whose identity matters), so an operator that must hold more than the budget
+// fails instead; a pipeline that can serialize its elements can spill them itself,
+// with ToJSONLines and FromJSONLines.
+type MemoryBudget struct {
+	mu    sync.Mutex
+	limit int64
+	used  int64
+	err   error
+}
+
+// Creates a budget of the given number of bytes
+func NewMemoryBudget(limit int64) *MemoryBudget {
+	return &MemoryBudget{limit: limit}
+}
+
+// Returns the number of bytes currently reserved
+func (b *MemoryBudget) Used() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used
+}
+
+// Returns ErrMemoryBudgetExceeded if any operator has exceeded the budget, or nil
+func (b *MemoryBudget) Err() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.err
+}
+
+// Makes a buffering operator hold its elements within the given budget,
+// estimating the size of each element with sizeOf, or, if it is nil,
+// from the shallow size of the element's type (which does not count
+// what the element points to, such as the contents of a string or slice).
+// sizeOf must give the same size each time it is called with the same element.
+// Operators that do not buffer elements ignore this option.
+func WithMemoryBudget(budget *MemoryBudget, sizeOf func(any) int64) Option {
+	if sizeOf == nil {
+		sizeOf = shallowSize
+	}
+	return func(c *stageConfig) {
+		c.budget, c.sizeOf = budget, sizeOf
+	}
+}
+
+func shallowSize(value any) int64 {
+	if value == nil {
+		return 0
+	}
+	return int64(reflect.TypeOf(value).Size())
+}
+
+// The memory one operator has reserved from a budget.
+// A nil account (an operator without WithMemoryBudget) holds anything.
+type memoryAccount struct {
+	budget *MemoryBudget
+	sizeOf func(any) int64
+	held   int64
+}
+
+// Returns a fresh account with the given configuration's budget for one run of an operator,
+// or nil if it has none
+func (c stageConfig) memoryAccount() *memoryAccount {
+	if c.budget == nil {
+		return nil
+	}
+	return &memoryAccount{budget: c.budget, sizeOf: c.sizeOf}
+}
+
+// Reserves memory for an element the operator is about to hold.
+// Reports false, and records ErrMemoryBudgetExceeded, if that would exceed the budget.
+func (a *memoryAccount) hold(value any) bool {
+	if a == nil {
+		return true
+	}
+	size := a.sizeOf(value)
+	b := a.budget
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.used+size > b.limit {
+		b.err = ErrMemoryBudgetExceeded
+		return false
+	}
+	b.used += size
+	a.held += size
+	return true
+}
+
+// Returns the memory for an element the operator no longer holds
+func (a *memoryAccount) release(value any) {
+	if a == nil {
+		return
+	}
+	size := a.sizeOf(value)
+	b := a.budget
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	size = min(size, a.held)
+	b.used -= size
+	a.held -= size
+}
+
+// Returns the memory for elements the operator no longer holds
+func releaseEach[T any](a *memoryAccount, values []T) {
+	for _, value := range values {
+		a.release(value)
+	}
+}
+
+// Returns all the memory the operator still holds, once it is finished
+func (a *memoryAccount) releaseAll() {
+	if a == nil {
+		return
+	}
+	b := a.budget
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.used -= a.held
+	a.held = 0
+}
@@ -0,0 +1,134 @@
+package gl
+
+import (
+	"container/list"
+	"context"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// The outcome of loading one key in CacheThrough.
+// ready is closed once value and err are set; loads of the same key
+// arriving while it is open wait for it instead of loading again.
+type cacheEntry[V any] struct {
+	value   V
+	err     error
+	expires time.Time
+	ready   chan struct{}
+}
+
+// Reports whether the entry has finished loading
+func (e *cacheEntry[V]) loaded() bool {
+	select {
+	case <-e.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+// Resolves each key received on a channel with the given load function
+// and sends the key and its value, or the error from loading it, on a new channel,
+// in the order the keys were received.
+// Values are cached for the given ttl, so a key seen again within it is not loaded again;
+// errors are not cached. Several keys are loaded at once, and a key that
+// is already being loaded waits for that load instead of starting another.
+func CacheThrough[K comparable, V any](source <-chan K, ttl time.Duration, load func(K) (V, error), options ...Option) <-chan Result[KeyValue[K, V]] {
+	if source == nil {
+		return nil
+	}
+	config := configure(options)
+	output, ctx, done := newConfiguredStage[Result[KeyValue[K, V]]](context.Background(), config, source)
+	window := max(runtime.NumCPU(), runtime.GOMAXPROCS(0))
+	pending := NewBuffered[KeyValue[K, *cacheEntry[V]]](window)
+	go func() {
+		defer close(pending)
+		cache := make(map[K]*cacheEntry[V])
+		lastSweep := time.Now()
+		for {
+			k, ok := receive(ctx, source)
+			if !ok {
+				return
+			}
+			now := time.Now()
+			if now.Sub(lastSweep) > ttl {
+				for key, e := range cache {
+					if e.loaded() && now.After(e.expires) {
+						delete(cache, key)
+					}
+				}
+				lastSweep = now
+			}
+			e := cache[k]
+			if e == nil || e.loaded() && (e.err != nil || now.After(e.expires)) {
+				e = &cacheEntry[V]{ready: make(chan struct{})}
+				cache[k] = e
+				go func() {
+					defer close(e.ready)
+					if config.recover != nil {
+						// Loads run on their own goroutines, out of reach of done
+						defer func() {
+							if r := recover(); r != nil {
+								e.err = newPanicError(r)
+								config.recover.record(e.err)
+								Stop((<-chan Result[KeyValue[K, V]])(output))
+							}
+						}()
+					}
+					e.value, e.err = load(k)
+					e.expires = time.Now().Add(ttl)
+				}()
+			}
+			if !send(ctx, pending, KeyValue[K, *cacheEntry[V]]{Key: k, Value: e}) {
+				return
+			}
+		}
+	}()
+	go func() {
+		defer done()
+		for p := range pending {
+			select {
+			case <-p.Value.ready:
+			case <-ctx.Done():
+				return
+			}
+			result := Result[KeyValue[K, V]]{Value: KeyValue[K, V]{Key: p.Key, Value: p.Value.value}, Err: p.Value.err}
+			if !send(ctx, output, result) {
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// Counts of how often MapMemo found a result in its cache
+type MemoStats struct {
+	Hits   atomic.Int64
+	Misses atomic.Int64
+}
+
+// Applies the given pure function to each value received on a channel
+// and sends the result on a new channel, remembering the results
+// for the n = maxEntries most recently used values so that a repeated value
+// is not computed again. A maxEntries of zero or less remembers every result.
+// Also returns counts of cache hits and misses so far.
+func MapMemo[T1 comparable, T2 any](source <-chan T1, f func(T1) T2, maxEntries int, options ...Option) (<-chan T2, *MemoStats) {
+	stats := new(MemoStats)
+	recent := list.New() // of KeyValue[T1, T2], most recently used first
+	entries := make(map[T1]*list.Element)
+	return Map(source, func(s T1) T2 {
+		if e, ok := entries[s]; ok {
+			stats.Hits.Add(1)
+			recent.MoveToFront(e)
+			return e.Value.(KeyValue[T1, T2]).Value
+		}
+		stats.Misses.Add(1)
+		result := f(s)
+		entries[s] = recent.PushFront(KeyValue[T1, T2]{Key: s, Value: result})
+		if maxEntries > 0 && recent.Len() > maxEntries {
+			delete(entries, recent.Remove(recent.Back()).(KeyValue[T1, T2]).Key)
+		}
+		return result
+	}, options...), stats
+}
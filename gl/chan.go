@@ -0,0 +1,59 @@
+package gl
+
+import "context"
+
+// Channel helpers.
+// Operators send and receive through Sender and Receiver,
+// so every send and receive in the package gives up the same way
+// once its context is done.
+
+// The sending end of a channel
+type Sender[T any] chan<- T
+
+// The receiving end of a channel
+type Receiver[T any] <-chan T
+
+// Sends a value on the channel unless the context is done first.
+// Reports whether the value was sent.
+func (s Sender[T]) Send(ctx context.Context, value T) bool {
+	select {
+	case s <- value:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Receives a value from the channel unless the context is done first.
+// Reports false if the channel is closed or the context is done.
+func (r Receiver[T]) Recv(ctx context.Context) (T, bool) {
+	select {
+	case value, ok := <-r:
+		return value, ok
+	case <-ctx.Done():
+		var zero T
+		return zero, false
+	}
+}
+
+// Create a channel that can hold n = size values before a send blocks
+func NewBuffered[T any](size int) chan T {
+	return make(chan T, max(size, 0))
+}
+
+// Create a channel that is already closed, so receiving from it yields nothing
+func Closed[T any]() <-chan T {
+	output := make(chan T)
+	close(output)
+	return output
+}
+
+// Shorthand for Sender.Send, used by the operators
+func send[T any](ctx context.Context, output chan<- T, value T) bool {
+	return Sender[T](output).Send(ctx, value)
+}
+
+// Shorthand for Receiver.Recv, used by the operators
+func receive[T any](ctx context.Context, source <-chan T) (T, bool) {
+	return Receiver[T](source).Recv(ctx)
+}
@@ -0,0 +1,184 @@
+package gl
+
+import "context"
+
+// Checkpoint barriers.
+// A barrier is a marker injected into a stream between its values.
+// It flows through the pipeline in order with the values around it,
+// so when it reaches the sink, every value before it has been fully processed
+// and none after it has, which makes that the moment to snapshot state
+// consistently across the whole pipeline.
+// Tee fans a marked stream out with its barriers; AlignBarriers fans it back in.
+
+// An element of a stream that carries checkpoint barriers along with its values:
+// either a value, or, if Barrier is not zero, the barrier of the checkpoint with that ID
+type Marked[T any] struct {
+	Value   T
+	Barrier uint64
+}
+
+// Reports whether the element is a barrier rather than a value
+func (m Marked[T]) IsBarrier() bool {
+	return m.Barrier != 0
+}
+
+// Sends each value received on a channel on a new channel, marked as a value,
+// and, whenever a checkpoint ID is received on the barriers channel,
+// a barrier with that ID between the values received before it and those after.
+// Checkpoint IDs should be increasing, and zero is ignored.
+// To checkpoint several sources that are later aligned together,
+// give each one the same IDs, for instance with Tee.
+func InjectBarriers[T any](source <-chan T, barriers <-chan uint64, options ...Option) <-chan Marked[T] {
+	if source == nil {
+		return nil
+	}
+	output, ctx, done := newConfiguredStage[Marked[T]](context.Background(), configure(options), source, barriers)
+	go func() {
+		defer done()
+		for {
+			var m Marked[T]
+			select {
+			case s, ok := <-source:
+				if !ok {
+					return
+				}
+				m = Marked[T]{Value: s}
+			case id, ok := <-barriers:
+				if !ok {
+					barriers = nil
+					continue
+				}
+				if id == 0 {
+					continue
+				}
+				m = Marked[T]{Barrier: id}
+			case <-ctx.Done():
+				return
+			}
+			if !send(ctx, output, m) {
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// Applies the given function to each value received on a marked channel
+// and sends the result on a new channel, passing barriers along unchanged
+func MapMarked[T1 any, T2 any](source <-chan Marked[T1], mapper func(T1) T2, options ...Option) <-chan Marked[T2] {
+	return Map(source, func(m Marked[T1]) Marked[T2] {
+		if m.IsBarrier() {
+			return Marked[T2]{Barrier: m.Barrier}
+		}
+		return Marked[T2]{Value: mapper(m.Value)}
+	}, options...)
+}
+
+// Sends the values received on a marked channel that satisfy the given predicate
+// on a new channel, passing barriers along unchanged
+func FilterMarked[T any](source <-chan Marked[T], predicate func(T) bool, options ...Option) <-chan Marked[T] {
+	return Filter(source, func(m Marked[T]) bool {
+		return m.IsBarrier() || predicate(m.Value)
+	}, options...)
+}
+
+// Something that happened on one of the sources of AlignBarriers
+type alignEvent[T any] struct {
+	input  int
+	value  Marked[T]
+	closed bool
+}
+
+// Merges the values received on the given marked channels into a new channel,
+// aligning their barriers: once a source delivers a barrier,
+// nothing more is received from it until every other open source has delivered
+// its own copy of that barrier, and then the barrier is sent once.
+// Every value sent before a barrier therefore precedes it on all sources,
+// and every value sent after it follows it on all sources.
+// Each source is expected to carry the same barriers in the same order;
+// a source that closes no longer holds the others back.
+// The new channel closes once every source has closed.
+func AlignBarriers[T any](sources []<-chan Marked[T], options ...Option) <-chan Marked[T] {
+	upstream := make([]any, len(sources))
+	for i, source := range sources {
+		upstream[i] = source
+	}
+	output, ctx, done := newConfiguredStage[Marked[T]](context.Background(), configure(options), upstream...)
+	events := make(chan alignEvent[T])
+	resume := make([]chan struct{}, len(sources))
+	for i, source := range sources {
+		resume[i] = make(chan struct{}, 1)
+		go func() {
+			for {
+				s, ok := receive(ctx, source)
+				if !ok {
+					send(ctx, events, alignEvent[T]{input: i, closed: true})
+					return
+				}
+				if !send(ctx, events, alignEvent[T]{input: i, value: s}) {
+					return
+				}
+				if s.IsBarrier() {
+					select {
+					case <-resume[i]:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}()
+	}
+	go func() {
+		defer done()
+		open := len(sources)
+		var waiting []int
+		var barrier uint64
+		for open > 0 {
+			e, ok := receive(ctx, events)
+			if !ok {
+				return
+			}
+			switch {
+			case e.closed:
+				open--
+			case !e.value.IsBarrier():
+				if !send(ctx, output, e.value) {
+					return
+				}
+			default:
+				if len(waiting) == 0 {
+					barrier = e.value.Barrier
+				}
+				waiting = append(waiting, e.input)
+			}
+			if len(waiting) > 0 && len(waiting) == open {
+				if !send(ctx, output, Marked[T]{Barrier: barrier}) {
+					return
+				}
+				for _, i := range waiting {
+					resume[i] <- struct{}{}
+				}
+				waiting = waiting[:0]
+			}
+		}
+	}()
+	return output
+}
+
+// Listens on a marked channel until it is closed, passing each value to consume
+// and calling snapshot with the ID of each barrier as it arrives,
+// at which point every value before the barrier has been consumed and none after it.
+// If snapshot returns an error, the channel is stopped and the error returned.
+func ForEachCheckpointed[T any](source <-chan Marked[T], consume func(T), snapshot func(barrier uint64) error) error {
+	for m := range source {
+		if !m.IsBarrier() {
+			consume(m.Value)
+			continue
+		}
+		if err := snapshot(m.Barrier); err != nil {
+			Stop(source)
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,278 @@
+package gl
+
+import (
+	"context"
+	"maps"
+)
+
+// Context-aware operators.
+// Each of these behaves like the operator of the same name without the Ctx suffix,
+// except that its goroutine stops receiving and sending, closes its output,
+// and exits as soon as the given context is cancelled.
+
+// Context-aware From
+func FromCtx[T any](ctx context.Context, source []T, options ...Option) <-chan T {
+	output, ctx, done := newConfiguredStage[T](ctx, configure(options))
+	go func() {
+		defer done()
+		for _, elem := range source {
+			if !send(ctx, output, elem) {
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// Context-aware Generate
+func GenerateCtx[S any, T any](ctx context.Context, seed S, next func(S) (T, S, bool), options ...Option) <-chan T {
+	output, ctx, done := newConfiguredStage[T](ctx, configure(options))
+	go func() {
+		defer done()
+		state := seed
+		for {
+			value, following, ok := next(state)
+			if !ok || !send(ctx, output, value) {
+				return
+			}
+			state = following
+		}
+	}()
+	return output
+}
+
+// Context-aware FromMap
+func FromMapCtx[K comparable, V any](ctx context.Context, source map[K]V, options ...Option) <-chan KeyValue[K, V] {
+	return FromSeq2Ctx(ctx, maps.All(source), options...)
+}
+
+// Context-aware FromString
+func FromStringCtx(ctx context.Context, source string, options ...Option) <-chan rune {
+	return FromSeqCtx(ctx, func(yield func(rune) bool) {
+		for _, r := range source {
+			if !yield(r) {
+				return
+			}
+		}
+	}, options...)
+}
+
+// Context-aware FromFunc
+func FromFuncCtx[T any](ctx context.Context, f func() (T, bool), options ...Option) <-chan T {
+	return GenerateCtx(ctx, struct{}{}, func(state struct{}) (T, struct{}, bool) {
+		value, ok := f()
+		return value, state, ok
+	}, options...)
+}
+
+// Context-aware FromPages
+func FromPagesCtx[T any](ctx context.Context, fetch func(pageToken string) (items []T, next string, err error), options ...Option) <-chan Result[T] {
+	output, ctx, done := newConfiguredStage[Result[T]](ctx, configure(options))
+	go func() {
+		defer done()
+		token := ""
+		for {
+			items, next, err := fetch(token)
+			if err != nil {
+				send(ctx, output, Fail[T](err))
+				return
+			}
+			for _, item := range items {
+				if !send(ctx, output, Ok(item)) {
+					return
+				}
+			}
+			if next == "" || ctx.Err() != nil {
+				return
+			}
+			token = next
+		}
+	}()
+	return output
+}
+
+// Context-aware Range
+func RangeCtx(ctx context.Context, start int, count int, options ...Option) <-chan int {
+	return GenerateCtx(ctx, start, func(i int) (int, int, bool) { return i, i + 1, i < start+count }, options...)
+}
+
+// Context-aware Repeat
+func RepeatCtx[T any](ctx context.Context, value T, count int, options ...Option) <-chan T {
+	return GenerateCtx(ctx, 0, func(i int) (T, int, bool) { return value, i + 1, i < count }, options...)
+}
+
+// Context-aware Fibonaccis
+func FibonaccisCtx(ctx context.Context, options ...Option) <-chan int {
+	return GenerateCtx(ctx, [2]int{1, 1}, func(pair [2]int) (int, [2]int, bool) {
+		return pair[0], [2]int{pair[1], pair[0] + pair[1]}, true
+	}, options...)
+}
+
+// Context-aware Map
+func MapCtx[T1 any, T2 any](ctx context.Context, source <-chan T1, mapper func(T1) T2, options ...Option) <-chan T2 {
+	if source == nil {
+		return nil
+	}
+	output, ctx, done := newConfiguredStage[T2](ctx, configure(options), source)
+	go func() {
+		defer done()
+		for {
+			s, ok := receive(ctx, source)
+			if !ok || !send(ctx, output, mapper(s)) {
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// Context-aware Filter
+func FilterCtx[T any](ctx context.Context, source <-chan T, predicate func(T) bool, options ...Option) <-chan T {
+	if source == nil {
+		return nil
+	}
+	output, ctx, done := newConfiguredStage[T](ctx, configure(options), source)
+	go func() {
+		defer done()
+		for {
+			s, ok := receive(ctx, source)
+			if !ok {
+				return
+			}
+			if predicate(s) && !send(ctx, output, s) {
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// Context-aware Zip
+func ZipCtx[T1 any, T2 any, T3 any](ctx context.Context, xs <-chan T1, ys <-chan T2, mapper func(T1, T2) T3, options ...Option) <-chan T3 {
+	if xs == nil || ys == nil {
+		return nil
+	}
+	output, ctx, done := newConfiguredStage[T3](ctx, configure(options), xs, ys)
+	go func() {
+		defer done()
+		for {
+			x, hasX := receive(ctx, xs)
+			if !hasX {
+				return
+			}
+			y, hasY := receive(ctx, ys)
+			if !hasY || !send(ctx, output, mapper(x, y)) {
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// Context-aware Scan
+func ScanCtx[T any, A any](ctx context.Context, source <-chan T, seed A, accumulate func(A, T) A, options ...Option) <-chan A {
+	if source == nil {
+		return nil
+	}
+	output, ctx, done := newConfiguredStage[A](ctx, configure(options), source)
+	go func() {
+		defer done()
+		acc := seed
+		for {
+			s, ok := receive(ctx, source)
+			if !ok {
+				return
+			}
+			acc = accumulate(acc, s)
+			if !send(ctx, output, acc) {
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// Context-aware Take
+func TakeCtx[T any](ctx context.Context, source <-chan T, count int, options ...Option) <-chan T {
+	if source == nil {
+		return nil
+	}
+	output, ctx, done := newConfiguredStage[T](ctx, configure(options), source)
+	go func() {
+		defer done()
+		for taken := 0; taken < count; taken++ {
+			s, ok := receive(ctx, source)
+			if !ok || !send(ctx, output, s) {
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// Context-aware Skip
+func SkipCtx[T any](ctx context.Context, source <-chan T, count int, options ...Option) <-chan T {
+	if source == nil {
+		return nil
+	}
+	output, ctx, done := newConfiguredStage[T](ctx, configure(options), source)
+	go func() {
+		defer done()
+		skipped := 0
+		for {
+			s, ok := receive(ctx, source)
+			if !ok {
+				return
+			}
+			skipped++
+			if skipped > count && !send(ctx, output, s) {
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// Context-aware TakeWhile
+func TakeWhileCtx[T any](ctx context.Context, source <-chan T, predicate func(T) bool, options ...Option) <-chan T {
+	if source == nil {
+		return nil
+	}
+	output, ctx, done := newConfiguredStage[T](ctx, configure(options), source)
+	go func() {
+		defer done()
+		for {
+			s, ok := receive(ctx, source)
+			if !ok || !predicate(s) || !send(ctx, output, s) {
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// Context-aware SkipWhile
+func SkipWhileCtx[T any](ctx context.Context, source <-chan T, predicate func(T) bool, options ...Option) <-chan T {
+	if source == nil {
+		return nil
+	}
+	output, ctx, done := newConfiguredStage[T](ctx, configure(options), source)
+	go func() {
+		defer done()
+		skipping := true
+		for {
+			s, ok := receive(ctx, source)
+			if !ok {
+				return
+			}
+			if skipping && predicate(s) {
+				continue
+			}
+			skipping = false
+			if !send(ctx, output, s) {
+				return
+			}
+		}
+	}()
+	return output
+}
@@ -0,0 +1,331 @@
+package gl
+
+import (
+	"context"
+	"encoding"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSV.
+// Records are decoded into (and encoded from) structs whose exported fields
+// are matched to columns by their csv tag, or else by name, ignoring case:
+//
+//	type Trade struct {
+//		Symbol string    `csv:"symbol"`
+//		Price  float64   `csv:"price"`
+//		At     time.Time `csv:"timestamp"` // RFC 3339
+//		Note   *string   `csv:"note"`      // nil when the column is empty
+//		Secret string    `csv:"-"`         // never read or written
+//	}
+//
+// Fields may be strings, booleans, integers, floats, time.Duration,
+// anything implementing encoding.TextUnmarshaler (and TextMarshaler) such as time.Time,
+// or pointers to any of those.
+
+// Configures how CSV is read or written
+type CSVOption func(*csvConfig)
+
+type csvConfig struct {
+	comma   rune
+	comment rune
+	header  []string
+}
+
+// Sets the field delimiter; the default is a comma
+func CSVComma(comma rune) CSVOption {
+	return func(c *csvConfig) { c.comma = comma }
+}
+
+// Makes lines beginning with the given character be ignored when reading
+func CSVComment(comment rune) CSVOption {
+	return func(c *csvConfig) { c.comment = comment }
+}
+
+// Gives the column names, for input without a header line;
+// without this, the first record read is taken as the header
+func CSVHeader(names ...string) CSVOption {
+	return func(c *csvConfig) { c.header = names }
+}
+
+func configureCSV(options []CSVOption) csvConfig {
+	config := csvConfig{comma: ','}
+	for _, option := range options {
+		option(&config)
+	}
+	return config
+}
+
+// Reads CSV records from the given reader, decodes each into a struct of type T,
+// and sends them on a new channel as successful Results.
+// A record that cannot be parsed or decoded is sent as a failed Result
+// and reading carries on; the channel closes at the end of the input,
+// or after a failed Result for an error from the reader itself.
+func FromCSV[T any](r io.Reader, options ...CSVOption) <-chan Result[T] {
+	return FromCSVCtx[T](context.Background(), r, options...)
+}
+
+// Context-aware FromCSV
+func FromCSVCtx[T any](ctx context.Context, r io.Reader, options ...CSVOption) <-chan Result[T] {
+	config := configureCSV(options)
+	output, ctx, done := newStage[Result[T]](ctx)
+	go func() {
+		defer done()
+		reader := csv.NewReader(r)
+		reader.Comma = config.comma
+		reader.Comment = config.comment
+		reader.ReuseRecord = true
+		header := config.header
+		if header == nil {
+			record, err := reader.Read()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				send(ctx, output, Fail[T](fmt.Errorf("gl: reading csv header: %w", err)))
+				return
+			}
+			header = slices.Clone(record)
+		}
+		columns, err := csvColumns(reflect.TypeFor[T](), header)
+		if err != nil {
+			send(ctx, output, Fail[T](err))
+			return
+		}
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				return
+			}
+			var parseErr *csv.ParseError
+			var result Result[T]
+			switch {
+			case errors.As(err, &parseErr):
+				result = Fail[T](fmt.Errorf("gl: reading csv: %w", err))
+			case err != nil:
+				send(ctx, output, Fail[T](fmt.Errorf("gl: reading csv: %w", err)))
+				return
+			default:
+				line, _ := reader.FieldPos(0)
+				result = decodeCSV[T](record, columns, line)
+			}
+			if !send(ctx, output, result) {
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// The struct field a column is decoded into, or encoded from
+type csvColumn struct {
+	name  string
+	field int // index of the field, or -1 if the column has none
+}
+
+// Matches the given column names to the fields of a struct type
+func csvColumns(t reflect.Type, header []string) ([]csvColumn, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("gl: csv records must be structs, not %v", t)
+	}
+	fields := csvFields(t)
+	columns := make([]csvColumn, len(header))
+	for i, name := range header {
+		columns[i] = csvColumn{name: name, field: -1}
+		for _, f := range fields {
+			if strings.EqualFold(f.name, strings.TrimSpace(name)) {
+				columns[i].field = f.field
+				break
+			}
+		}
+	}
+	return columns, nil
+}
+
+// Returns the exported fields of a struct type along with their column names, in order
+func csvFields(t reflect.Type) []csvColumn {
+	var fields []csvColumn
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("csv"); ok {
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+		fields = append(fields, csvColumn{name: name, field: i})
+	}
+	return fields
+}
+
+// Decodes one record into a struct according to its columns
+func decodeCSV[T any](record []string, columns []csvColumn, line int) Result[T] {
+	var value T
+	target := reflect.ValueOf(&value).Elem()
+	for i, text := range record {
+		if i >= len(columns) || columns[i].field < 0 {
+			continue
+		}
+		if err := parseCSVField(target.Field(columns[i].field), text); err != nil {
+			return Fail[T](fmt.Errorf("gl: csv line %d, column %q: %w", line, columns[i].name, err))
+		}
+	}
+	return Ok(value)
+}
+
+var durationType = reflect.TypeFor[time.Duration]()
+
+// Sets a field from the text of a CSV field
+func parseCSVField(field reflect.Value, text string) error {
+	if field.Kind() == reflect.Pointer {
+		if text == "" {
+			field.SetZero()
+			return nil
+		}
+		field.Set(reflect.New(field.Type().Elem()))
+		field = field.Elem()
+	}
+	if text == "" && field.Kind() != reflect.String {
+		field.SetZero()
+		return nil
+	}
+	if u, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(text))
+	}
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(text)
+		field.SetInt(int64(d))
+		return err
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(text)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		field.SetBool(b)
+		return err
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(text, 10, field.Type().Bits())
+		field.SetInt(n)
+		return err
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(text, 10, field.Type().Bits())
+		field.SetUint(n)
+		return err
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(text, field.Type().Bits())
+		field.SetFloat(f)
+		return err
+	default:
+		return fmt.Errorf("unsupported field type %v", field.Type())
+	}
+	return nil
+}
+
+// Listens on a channel until it is closed and writes each struct received
+// to w as a CSV record, after a header line naming the columns.
+// The columns are those given with CSVHeader, matched to fields as FromCSV does,
+// or else every field of T in order. Each record is flushed as it is written,
+// so a large stream is never buffered. If writing or formatting a field fails,
+// the channel is stopped and the error returned.
+func ToCSV[T any](source <-chan T, w io.Writer, options ...CSVOption) error {
+	config := configureCSV(options)
+	writer := csv.NewWriter(w)
+	writer.Comma = config.comma
+	t := reflect.TypeFor[T]()
+	var columns []csvColumn
+	var err error
+	if config.header != nil {
+		columns, err = csvColumns(t, config.header)
+	} else if t.Kind() != reflect.Struct {
+		err = fmt.Errorf("gl: csv records must be structs, not %v", t)
+	} else {
+		columns = csvFields(t)
+	}
+	if err == nil {
+		header := make([]string, len(columns))
+		for i, c := range columns {
+			header[i] = c.name
+		}
+		err = writeCSV(writer, header)
+	}
+	record := make([]string, len(columns))
+	for s := range source {
+		if err != nil {
+			Stop(source)
+			return err
+		}
+		value := reflect.New(t).Elem()
+		value.Set(reflect.ValueOf(&s).Elem())
+		for i, c := range columns {
+			record[i] = ""
+			if c.field < 0 {
+				continue
+			}
+			if record[i], err = formatCSVField(value.Field(c.field)); err != nil {
+				err = fmt.Errorf("gl: writing csv, column %q: %w", c.name, err)
+				break
+			}
+		}
+		if err == nil {
+			err = writeCSV(writer, record)
+		}
+	}
+	return err
+}
+
+// Writes and flushes one record
+func writeCSV(writer *csv.Writer, record []string) error {
+	if err := writer.Write(record); err != nil {
+		return fmt.Errorf("gl: writing csv: %w", err)
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("gl: writing csv: %w", err)
+	}
+	return nil
+}
+
+// Returns the text of a CSV field for an addressable struct field,
+// or the error from its MarshalText method
+func formatCSVField(field reflect.Value) (string, error) {
+	if field.Kind() == reflect.Pointer {
+		if field.IsNil() {
+			return "", nil
+		}
+		field = field.Elem()
+	}
+	if m, ok := field.Addr().Interface().(encoding.TextMarshaler); ok {
+		text, err := m.MarshalText()
+		return string(text), err
+	}
+	if field.Type() == durationType {
+		return time.Duration(field.Int()).String(), nil
+	}
+	switch field.Kind() {
+	case reflect.String:
+		return field.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(field.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(field.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(field.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(field.Float(), 'g', -1, field.Type().Bits()), nil
+	}
+	return fmt.Sprint(field.Interface()), nil
+}
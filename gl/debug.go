@@ -0,0 +1,74 @@
+package gl
+
+import "sync"
+
+// A Debugger retains a bounded sample of the elements flowing
+// through each named stage of a pipeline, so intermediate data
+// can be looked at while the pipeline is running.
+type Debugger struct {
+	mu       sync.Mutex
+	capacity int
+	every    int
+	stages   map[string]*stageSamples
+}
+
+type stageSamples struct {
+	seen    int
+	samples *ring[any]
+}
+
+// Creates a Debugger that keeps up to capacity samples per stage,
+// sampling every nth element (every <= 1 samples all elements)
+func NewDebugger(capacity int, every int) *Debugger {
+	return &Debugger{
+		capacity: capacity,
+		every:    max(every, 1),
+		stages:   make(map[string]*stageSamples),
+	}
+}
+
+// Passes each element received on a channel through unchanged to a new channel,
+// recording samples in the given Debugger under the given stage name.
+// If the Debugger is nil, debugging is off and the source is returned as is.
+func DebugStage[T any](d *Debugger, stage string, source <-chan T) <-chan T {
+	if d == nil || source == nil {
+		return source
+	}
+	return Tap(source, func(s T) { d.record(stage, s) })
+}
+
+func (d *Debugger) record(stage string, value any) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	st, ok := d.stages[stage]
+	if !ok {
+		st = &stageSamples{samples: newRing[any](d.capacity)}
+		d.stages[stage] = st
+	}
+	if st.seen%d.every == 0 {
+		st.samples.push(value)
+	}
+	st.seen++
+}
+
+// Returns the samples currently retained for the given stage, oldest first
+func (d *Debugger) Inspect(stage string) []any {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	st, ok := d.stages[stage]
+	if !ok {
+		return nil
+	}
+	return st.samples.values()
+}
+
+// Returns the number of elements that have passed through the given stage
+func (d *Debugger) Seen(stage string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	st, ok := d.stages[stage]
+	if !ok {
+		return 0
+	}
+	return st.seen
+}
@@ -0,0 +1,297 @@
+package gl
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A KVStore remembers which idempotency keys have been seen,
+// ideally somewhere that survives a restart (a file, bolt, Redis, ...)
+type KVStore interface {
+	// Reports whether the key has been marked and has not yet expired
+	Seen(key string) (bool, error)
+	// Marks the key as seen until the given time;
+	// a zero time means the key never expires
+	Mark(key string, expires time.Time) error
+}
+
+// Forwards each value from a channel whose key has not been seen before
+// according to the given store, marking its key as seen for the given ttl
+// (zero or less meaning forever) once it has been received downstream.
+// If the store returns an error, onError (if not nil) is called with it
+// and the value is forwarded anyway, preferring reprocessing over losing an event;
+// an error marking a key means it may be reprocessed after a restart.
+func DedupPersistent[T any](source <-chan T, key func(T) string, store KVStore, ttl time.Duration, onError func(error), options ...Option) <-chan T {
+	if source == nil {
+		return nil
+	}
+	output, ctx, done := newConfiguredStage[T](context.Background(), configure(options), source)
+	go func() {
+		defer done()
+		for s := range source {
+			k := key(s)
+			seen, err := store.Seen(k)
+			if err != nil && onError != nil {
+				onError(fmt.Errorf("gl: checking key %q: %w", k, err))
+			}
+			if err == nil && seen {
+				continue
+			}
+			if !send(ctx, output, s) {
+				return
+			}
+			var expires time.Time
+			if ttl > 0 {
+				expires = time.Now().Add(ttl)
+			}
+			if err := store.Mark(k, expires); err != nil && onError != nil {
+				onError(fmt.Errorf("gl: marking key %q: %w", k, err))
+			}
+		}
+	}()
+	return output
+}
+
+// A KVStore held in memory; useful for tests and for deduplicating within a single run
+type MemoryStore struct {
+	mu      sync.Mutex
+	expires map[string]time.Time
+}
+
+// Creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{expires: make(map[string]time.Time)}
+}
+
+func (m *MemoryStore) Seen(key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	expires, ok := m.expires[key]
+	if !ok {
+		return false, nil
+	}
+	if !expires.IsZero() && time.Now().After(expires) {
+		delete(m.expires, key)
+		return false, nil
+	}
+	return true, nil
+}
+
+func (m *MemoryStore) Mark(key string, expires time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expires[key] = expires
+	return nil
+}
+
+// Drops the expired keys and returns the rest along with when they expire
+func (m *MemoryStore) unexpired() map[string]time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for key, expires := range m.expires {
+		if !expires.IsZero() && now.After(expires) {
+			delete(m.expires, key)
+		}
+	}
+	return maps.Clone(m.expires)
+}
+
+// A KVStore backed by an append-only file, one marked key per line.
+// The file is read back in full when the store is opened,
+// and rewritten without expired or superseded lines then and by Compact.
+type FileStore struct {
+	memory *MemoryStore
+	mu     sync.Mutex
+	path   string
+	file   *os.File
+}
+
+// Opens (creating if necessary) the FileStore at the given path,
+// loads the unexpired keys recorded in it, and compacts it
+func OpenFileStore(path string) (*FileStore, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	store := &FileStore{memory: NewMemoryStore(), path: path, file: file}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		nanos, quoted, found := strings.Cut(scanner.Text(), "\t")
+		if !found {
+			continue
+		}
+		n, err := strconv.ParseInt(nanos, 10, 64)
+		if err != nil {
+			continue
+		}
+		key, err := strconv.Unquote(quoted)
+		if err != nil {
+			continue
+		}
+		var expires time.Time
+		if n != 0 {
+			expires = time.Unix(0, n)
+		}
+		store.memory.Mark(key, expires)
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := store.Compact(); err != nil {
+		store.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// Rewrites the file with one line per unexpired key, so that it does not grow
+// without limit as keys expire or are marked again.
+// It is done when the store is opened; long-running processes can also call it periodically.
+func (f *FileStore) Compact() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	temp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return fmt.Errorf("gl: compacting file store: %w", err)
+	}
+	defer os.Remove(temp.Name()) // fails harmlessly once renamed
+	writer := bufio.NewWriter(temp)
+	for key, expires := range f.memory.unexpired() {
+		writeFileStoreLine(writer, key, expires)
+	}
+	err = writer.Flush()
+	if err == nil {
+		err = temp.Chmod(0o644)
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), f.path)
+	}
+	if err != nil {
+		return fmt.Errorf("gl: compacting file store: %w", err)
+	}
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return fmt.Errorf("gl: compacting file store: %w", err)
+	}
+	f.file.Close()
+	f.file = file
+	return nil
+}
+
+// Writes the line recording a marked key
+func writeFileStoreLine(w io.Writer, key string, expires time.Time) error {
+	var nanos int64
+	if !expires.IsZero() {
+		nanos = expires.UnixNano()
+	}
+	_, err := fmt.Fprintf(w, "%d\t%s\n", nanos, strconv.Quote(key))
+	return err
+}
+
+func (f *FileStore) Seen(key string) (bool, error) {
+	return f.memory.Seen(key)
+}
+
+func (f *FileStore) Mark(key string, expires time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := writeFileStoreLine(f.file, key, expires); err != nil {
+		return err
+	}
+	return f.memory.Mark(key, expires)
+}
+
+// Closes the underlying file
+func (f *FileStore) Close() error {
+	return f.file.Close()
+}
+
+// A value along with a count, such as the number of duplicates of it suppressed
+type Counted[T any] struct {
+	Value T
+	Count int
+}
+
+// Forwards the first occurrence of each value received on a channel straight away,
+// with a count of zero, and suppresses any duplicates of it for the given window.
+// When the window ends, if any duplicates were suppressed, the value is sent again
+// with the number suppressed as its count (the usual behavior for deduplicating alerts).
+// The next occurrence after that starts a new window.
+// Once the source closes, the counts of windows still open are sent straight away.
+func CollapseDuplicates[T comparable](source <-chan T, window time.Duration, options ...Option) <-chan Counted[T] {
+	if source == nil {
+		return nil
+	}
+	type collapse struct {
+		value      T
+		ends       time.Time
+		suppressed int
+	}
+	output, ctx, done := newConfiguredStage[Counted[T]](context.Background(), configure(options), source)
+	go func() {
+		defer done()
+		open := make(map[T]*collapse)
+		var queue []*collapse // in order of first occurrence, and so of when their windows end
+		// Ends the windows in the queue up to the given time, sending their counts
+		flush := func(until time.Time) bool {
+			for len(queue) > 0 && !queue[0].ends.After(until) {
+				c := queue[0]
+				queue = queue[1:]
+				delete(open, c.value)
+				if c.suppressed > 0 && !send(ctx, output, Counted[T]{Value: c.value, Count: c.suppressed}) {
+					return false
+				}
+			}
+			return true
+		}
+		timer := time.NewTimer(window)
+		defer timer.Stop()
+		for {
+			var windowEnd <-chan time.Time
+			if len(queue) > 0 {
+				timer.Reset(time.Until(queue[0].ends))
+				windowEnd = timer.C
+			}
+			select {
+			case s, ok := <-source:
+				if !ok {
+					if len(queue) > 0 {
+						flush(queue[len(queue)-1].ends)
+					}
+					return
+				}
+				if c, collapsing := open[s]; collapsing {
+					c.suppressed++
+					continue
+				}
+				c := &collapse{value: s, ends: time.Now().Add(window)}
+				open[s] = c
+				queue = append(queue, c)
+				if !send(ctx, output, Counted[T]{Value: s}) {
+					return
+				}
+			case now := <-windowEnd:
+				if !flush(now) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return output
+}
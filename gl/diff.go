@@ -0,0 +1,146 @@
+package gl
+
+import "context"
+
+// The kind of difference a Change describes
+type ChangeKind int
+
+const (
+	Added ChangeKind = iota
+	Removed
+	Modified
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Modified:
+		return "modified"
+	}
+	return "unknown"
+}
+
+// A difference between two versions of a record.
+// Old is the zero value for Added changes and New is the zero value for Removed changes.
+type Change[T any] struct {
+	Kind ChangeKind
+	Old  T
+	New  T
+}
+
+// Compares two streams of records, matched up by key,
+// and sends the records that were added, removed, or modified on a new channel.
+// The old stream is buffered in full first;
+// the current stream is then compared record by record as it arrives,
+// and removals are sent, in their old order, once it closes.
+// When a key repeats, the last record with it wins: in the old stream it replaces
+// the earlier ones, and in the current stream it is compared with the earlier one,
+// so that it is reported as Modified if it differs. Each current key's latest record is kept.
+func DiffStreams[T comparable, K comparable](old <-chan T, current <-chan T, key func(T) K) <-chan Change[T] {
+	if old == nil || current == nil {
+		return nil
+	}
+	output, ctx, done := newStage[Change[T]](context.Background(), old, current)
+	go func() {
+		defer done()
+		var order []K
+		previous := make(map[K]T)
+		for s := range old {
+			k := key(s)
+			if _, dup := previous[k]; !dup {
+				order = append(order, k)
+			}
+			previous[k] = s
+		}
+		latest := make(map[K]T) // of each key seen so far in current
+		for s := range current {
+			k := key(s)
+			before, existed := latest[k]
+			if !existed {
+				before, existed = previous[k]
+				delete(previous, k)
+			}
+			latest[k] = s
+			if !existed {
+				if !send(ctx, output, Change[T]{Kind: Added, New: s}) {
+					return
+				}
+				continue
+			}
+			if before != s {
+				if !send(ctx, output, Change[T]{Kind: Modified, Old: before, New: s}) {
+					return
+				}
+			}
+		}
+		for _, k := range order {
+			if before, remaining := previous[k]; remaining {
+				if !send(ctx, output, Change[T]{Kind: Removed, Old: before}) {
+					return
+				}
+			}
+		}
+	}()
+	return output
+}
+
+// Compares each full snapshot of a set of records received on a channel
+// (such as the result of polling an API's list call) with the one before it,
+// matching records up by key, and sends the records that were added, removed,
+// or modified (according to equal) on a new channel,
+// so downstream consumers only process what changed.
+// Everything in the first snapshot counts as added.
+// The changes for each snapshot are sent in its order, followed by removals
+// in the order of the previous snapshot.
+func DiffSnapshots[T any, K comparable](snapshots <-chan []T, key func(T) K, equal func(T, T) bool) <-chan Change[T] {
+	if snapshots == nil {
+		return nil
+	}
+	output, ctx, done := newStage[Change[T]](context.Background(), snapshots)
+	go func() {
+		defer done()
+		var order []K
+		previous := make(map[K]T)
+		for snapshot := range snapshots {
+			var nextOrder []K
+			next := make(map[K]T, len(snapshot))
+			for _, s := range snapshot {
+				k := key(s)
+				if _, dup := next[k]; !dup {
+					nextOrder = append(nextOrder, k)
+				}
+				next[k] = s
+			}
+			for _, k := range nextOrder {
+				s := next[k]
+				before, existed := previous[k]
+				var change Change[T]
+				switch {
+				case !existed:
+					change = Change[T]{Kind: Added, New: s}
+				case !equal(before, s):
+					change = Change[T]{Kind: Modified, Old: before, New: s}
+				default:
+					continue
+				}
+				if !send(ctx, output, change) {
+					return
+				}
+			}
+			for _, k := range order {
+				if before, existed := previous[k]; existed {
+					if _, remaining := next[k]; !remaining {
+						if !send(ctx, output, Change[T]{Kind: Removed, Old: before}) {
+							return
+						}
+					}
+				}
+			}
+			order, previous = nextOrder, next
+		}
+	}()
+	return output
+}
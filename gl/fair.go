@@ -0,0 +1,138 @@
+package gl
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// The per-tenant queues of MergeFair.
+// Readers add to their tenant's queue; the merger takes from the queues
+// in weighted round-robin order, skipping tenants with nothing queued.
+type fairQueues[T any] struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queues  [][]T
+	weights []int
+	limit   int
+	open    int // readers still running
+	current int // tenant being served
+	quota   int // values the current tenant may still send this turn
+	closed  bool
+}
+
+// Adds a value to the given tenant's queue, waiting while it already holds limit values.
+// Reports false if the queues were closed first.
+func (q *fairQueues[T]) put(tenant int, value T) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.queues[tenant]) >= q.limit && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed {
+		return false
+	}
+	q.queues[tenant] = append(q.queues[tenant], value)
+	q.cond.Broadcast()
+	return true
+}
+
+// Records that a reader has finished
+func (q *fairQueues[T]) finish() {
+	q.mu.Lock()
+	q.open--
+	q.mu.Unlock()
+	q.cond.Broadcast()
+}
+
+// Takes the next value in weighted round-robin order.
+// Reports false once every reader has finished and the queues are empty,
+// or once the queues are closed.
+func (q *fairQueues[T]) take() (T, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for {
+		if q.closed || len(q.queues) == 0 {
+			var zero T
+			return zero, false
+		}
+		queued := false
+		for range len(q.queues) + 1 {
+			if own := q.queues[q.current]; len(own) > 0 {
+				queued = true
+				if q.quota > 0 {
+					value := own[0]
+					q.queues[q.current] = own[1:]
+					q.quota--
+					q.cond.Broadcast()
+					return value, true
+				}
+			}
+			q.current = (q.current + 1) % len(q.queues)
+			q.quota = q.weights[q.current]
+		}
+		if !queued && q.open == 0 {
+			var zero T
+			return zero, false
+		}
+		q.cond.Wait()
+	}
+}
+
+// Wakes everyone up and makes put and take give up
+func (q *fairQueues[T]) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.cond.Broadcast()
+}
+
+// Merges the values received on several tenants' channels into a new channel,
+// taking turns between tenants in order of name so that one noisy tenant
+// cannot starve the others. On its turn, a tenant sends up to its weight in values
+// (weights missing or below one count as one); tenants with nothing waiting are skipped.
+// Nil sources are ignored. The new channel closes once every source has closed.
+func MergeFair[T any](sources map[string]<-chan T, weights map[string]int, options ...Option) <-chan T {
+	names := slices.Sorted(func(yield func(string) bool) {
+		for name, source := range sources {
+			if source != nil && !yield(name) {
+				return
+			}
+		}
+	})
+	upstream := make([]any, len(names))
+	q := &fairQueues[T]{queues: make([][]T, len(names)), weights: make([]int, len(names)), open: len(names)}
+	for i, name := range names {
+		upstream[i] = sources[name]
+		q.weights[i] = max(weights[name], 1)
+		q.limit = max(q.limit, q.weights[i])
+	}
+	q.cond = sync.NewCond(&q.mu)
+	if len(names) > 0 {
+		q.quota = q.weights[0]
+	}
+	output, ctx, done := newConfiguredStage[T](context.Background(), configure(options), upstream...)
+	context.AfterFunc(ctx, q.close)
+	for i, name := range names {
+		source := sources[name]
+		go func() {
+			defer q.finish()
+			for {
+				s, ok := receive(ctx, source)
+				if !ok || !q.put(i, s) {
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		defer done()
+		for {
+			s, ok := q.take()
+			if !ok || !send(ctx, output, s) {
+				return
+			}
+		}
+	}()
+	return output
+}
@@ -0,0 +1,50 @@
+package gl
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// Collects everything from a channel, failing the test if it does not close in time
+func collectWithin[T any](t *testing.T, source <-chan T, limit time.Duration) []T {
+	t.Helper()
+	var values []T
+	deadline := time.After(limit)
+	for {
+		select {
+		case s, ok := <-source:
+			if !ok {
+				return values
+			}
+			values = append(values, s)
+		case <-deadline:
+			t.Fatalf("channel still open after %v, having sent %v", limit, values)
+		}
+	}
+}
+
+func TestMergeFairEmpty(t *testing.T) {
+	if got := collectWithin(t, MergeFair[int](nil, nil), time.Second); len(got) != 0 {
+		t.Fatalf("got %v, want nothing", got)
+	}
+	if got := collectWithin(t, MergeFair(map[string]<-chan int{}, nil), time.Second); len(got) != 0 {
+		t.Fatalf("got %v, want nothing", got)
+	}
+}
+
+func TestMergeFairNilSource(t *testing.T) {
+	sources := map[string]<-chan int{
+		"a": From([]int{1, 2, 3}),
+		"b": nil,
+		"c": From([]int{4, 5}),
+	}
+	got := collectWithin(t, MergeFair(sources, nil), time.Second)
+	slices.Sort(got)
+	if want := []int{1, 2, 3, 4, 5}; !slices.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got := collectWithin(t, MergeFair(map[string]<-chan int{"only": nil}, nil), time.Second); len(got) != 0 {
+		t.Fatalf("got %v, want nothing", got)
+	}
+}
@@ -0,0 +1,186 @@
+package gl
+
+import "context"
+
+// Stage fusion.
+// Every operator runs on its own goroutine and hands each value to the next over a channel.
+// A Fusion instead collects consecutive Map, Filter, Take, Skip, TakeWhile and SkipWhile steps
+// and runs them all on a single goroutine, as one function call per value,
+// so a long chain pays for one channel hop rather than one per step.
+
+// One fused step: given a value from the source, returns the resulting value,
+// whether it is kept, and whether further values should be received
+type fusedStep[S any, T any] func(S) (value T, keep bool, more bool)
+
+// A chain of steps to be run on a single goroutine over the values of a source channel of S,
+// producing values of T.
+// Go methods cannot introduce type parameters, so a map that changes the element type
+// is the free function FuseMap.
+type Fusion[S any, T any] struct {
+	source <-chan S
+	build  func() fusedStep[S, T] // fresh step state for each run
+	// Set once a step keeps no values at all (such as Take(0)),
+	// so that a run ends without receiving anything
+	empty bool
+}
+
+// Starts a Fusion over the given channel
+func Fuse[T any](source <-chan T) Fusion[T, T] {
+	return Fusion[T, T]{source: source, build: func() fusedStep[T, T] {
+		return func(s T) (T, bool, bool) { return s, true, true }
+	}}
+}
+
+// Adds a step that applies a function that changes the element type
+func FuseMap[S any, T1 any, T2 any](f Fusion[S, T1], mapper func(T1) T2) Fusion[S, T2] {
+	return Fusion[S, T2]{source: f.source, empty: f.empty, build: func() fusedStep[S, T2] {
+		previous := f.build()
+		return func(s S) (T2, bool, bool) {
+			value, keep, more := previous(s)
+			if !keep {
+				var zero T2
+				return zero, false, more
+			}
+			return mapper(value), true, more
+		}
+	}}
+}
+
+// Adds a step like Map
+func (f Fusion[S, T]) Map(mapper func(T) T) Fusion[S, T] {
+	return FuseMap(f, mapper)
+}
+
+// Adds a step like Filter
+func (f Fusion[S, T]) Filter(predicate func(T) bool) Fusion[S, T] {
+	return f.then(func() func(T) (bool, bool) {
+		return func(value T) (bool, bool) { return predicate(value), true }
+	})
+}
+
+// Adds a step like Take; no more values are received once count have been kept,
+// and none at all if count is zero or less
+func (f Fusion[S, T]) Take(count int) Fusion[S, T] {
+	if count <= 0 {
+		f.empty = true
+	}
+	return f.then(func() func(T) (bool, bool) {
+		taken := 0
+		return func(value T) (bool, bool) {
+			if taken >= count {
+				return false, false
+			}
+			taken++
+			return true, taken < count
+		}
+	})
+}
+
+// Adds a step like Skip
+func (f Fusion[S, T]) Skip(count int) Fusion[S, T] {
+	return f.then(func() func(T) (bool, bool) {
+		skipped := 0
+		return func(value T) (bool, bool) {
+			if skipped < count {
+				skipped++
+				return false, true
+			}
+			return true, true
+		}
+	})
+}
+
+// Adds a step like TakeWhile
+func (f Fusion[S, T]) TakeWhile(predicate func(T) bool) Fusion[S, T] {
+	return f.then(func() func(T) (bool, bool) {
+		return func(value T) (bool, bool) {
+			if !predicate(value) {
+				return false, false
+			}
+			return true, true
+		}
+	})
+}
+
+// Adds a step like SkipWhile
+func (f Fusion[S, T]) SkipWhile(predicate func(T) bool) Fusion[S, T] {
+	return f.then(func() func(T) (bool, bool) {
+		skipping := true
+		return func(value T) (bool, bool) {
+			if skipping && predicate(value) {
+				return false, true
+			}
+			skipping = false
+			return true, true
+		}
+	})
+}
+
+// Adds a step that keeps the value or not, and may end the run,
+// without changing it. The gate is built afresh for each run so it can hold state.
+func (f Fusion[S, T]) then(gate func() func(T) (keep bool, more bool)) Fusion[S, T] {
+	return Fusion[S, T]{source: f.source, empty: f.empty, build: func() fusedStep[S, T] {
+		previous := f.build()
+		next := gate()
+		return func(s S) (T, bool, bool) {
+			value, keep, more := previous(s)
+			if !keep {
+				return value, false, more
+			}
+			keep, ok := next(value)
+			return value, keep, more && ok
+		}
+	}}
+}
+
+// Runs the fused steps on a single goroutine
+// and sends the resulting values on a new channel
+func (f Fusion[S, T]) Chan(options ...Option) <-chan T {
+	if f.source == nil {
+		return nil
+	}
+	output, ctx, done := newConfiguredStage[T](context.Background(), configure(options), f.source)
+	go func() {
+		defer done()
+		if f.empty {
+			return
+		}
+		step := f.build()
+		for {
+			s, ok := receive(ctx, f.source)
+			if !ok {
+				return
+			}
+			value, keep, more := step(s)
+			if keep && !send(ctx, output, value) {
+				return
+			}
+			if !more {
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// Runs the fused steps on the calling goroutine, without any further channel,
+// and returns the resulting values in a slice
+func (f Fusion[S, T]) ToSlice() []T {
+	var elements []T
+	if f.empty {
+		Stop(f.source)
+		return elements
+	}
+	step := f.build()
+	for s := range f.source {
+		value, keep, more := step(s)
+		if keep {
+			elements = append(elements, value)
+		}
+		if !more {
+			Stop(f.source)
+			break
+		}
+	}
+	return elements
+}
@@ -2,41 +2,223 @@ package gl
 
 import (
 	"cmp"
+	"context"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // For each element in a channel, apply the given map function
 // and send the result on a new channel.
 // That new channel is returned.
-func Map[T1 any, T2 any](source chan T1, mapper func(T1) T2) chan T2 {
+func Map[T1 any, T2 any](source <-chan T1, mapper func(T1) T2, options ...Option) <-chan T2 {
+	return MapCtx(context.Background(), source, mapper, options...)
+}
+
+// Like Map, but the mapper is also given an emit function with which it may
+// send any number of values to named side channels ("metrics", "errors", ...).
+// Returns the main output channel and one side channel per given name;
+// values emitted to names that were not given are dropped.
+// All channels are unbuffered and closed together, so every one of them must be drained.
+func MapWithSideOutputs[T1 any, T2 any](source <-chan T1, mapper func(value T1, emit func(name string, value any)) T2, names ...string) (<-chan T2, map[string]<-chan any) {
+	if source == nil {
+		return nil, nil
+	}
+	output, ctx, done := newStage[T2](context.Background(), source)
+	sides := make(map[string]chan any, len(names))
+	receivers := make(map[string]<-chan any, len(names))
+	for _, name := range names {
+		sides[name] = NewBuffered[any](0)
+		receivers[name] = sides[name]
+	}
+	emit := func(name string, value any) {
+		if side, ok := sides[name]; ok {
+			send(ctx, side, value)
+		}
+	}
+	go func() {
+		defer func() {
+			for _, side := range sides {
+				close(side)
+			}
+		}()
+		defer done()
+		for s := range source {
+			if !send(ctx, output, mapper(s, emit)) {
+				return
+			}
+		}
+	}()
+	return output, receivers
+}
+
+// Routes each value received on a channel to one of n new channels,
+// the one whose index classify returns, or to a fallthrough channel
+// if the index is negative or not less than n.
+// Returns the n channels and the fallthrough channel.
+// Every channel must be drained or stopped, since a value waits for its channel to be received from.
+// Values routed to a stopped channel are dropped, and once all the channels are stopped,
+// so is the source.
+func PartitionN[T any](source <-chan T, classify func(T) int, n int, options ...Option) ([]<-chan T, <-chan T) {
+	if source == nil {
+		return nil, nil
+	}
+	config := configure(options)
+	rest, _, done := newConfiguredStage[T](context.Background(), config, source)
+	outputs := make([]chan T, max(n, 0)+1)
+	for i := range outputs[:len(outputs)-1] {
+		outputs[i] = NewBuffered[T](config.buffer)
+	}
+	outputs[len(outputs)-1] = rest
+	// Each output is abandoned when it is stopped, and the whole stage once they all are
+	abandoned := make([]chan struct{}, len(outputs))
+	allAbandoned := make(chan struct{})
+	remaining := new(atomic.Int32)
+	remaining.Store(int32(len(outputs)))
+	for i, output := range outputs {
+		abandoned[i] = make(chan struct{})
+		var once sync.Once
+		onStop(output, func() {
+			once.Do(func() {
+				close(abandoned[i])
+				if remaining.Add(-1) == 0 {
+					close(allAbandoned)
+				}
+			})
+		})
+	}
+	go func() {
+		defer func() {
+			for _, output := range outputs[:len(outputs)-1] {
+				forgetStop(output)
+				close(output)
+			}
+		}()
+		defer done()
+		for {
+			var s T
+			select {
+			case value, ok := <-source:
+				if !ok {
+					return
+				}
+				s = value
+			case <-allAbandoned:
+				return
+			}
+			i := classify(s)
+			if i < 0 || i >= len(outputs)-1 {
+				i = len(outputs) - 1
+			}
+			select {
+			case outputs[i] <- s:
+			case <-abandoned[i]:
+			case <-allAbandoned:
+				return
+			}
+		}
+	}()
+	partitions := make([]<-chan T, len(outputs)-1)
+	for i := range partitions {
+		partitions[i] = outputs[i]
+	}
+	return partitions, rest
+}
+
+// Starting from the given seed, combines each element of a channel
+// into an accumulator with the given function,
+// and sends each intermediate accumulator value on a new channel.
+func Scan[T any, A any](source <-chan T, seed A, accumulate func(A, T) A, options ...Option) <-chan A {
+	return ScanCtx(context.Background(), source, seed, accumulate, options...)
+}
+
+// Threads a state through the values received on a channel, starting from initial.
+// For each value, step returns the next state and any number of results
+// (none, one, or several), which are sent on a new channel in order.
+// Suits sessionization, protocol parsing, and other pattern-matching stages.
+func StateMachine[S any, T any, R any](source <-chan T, initial S, step func(S, T) (S, []R), options ...Option) <-chan R {
+	return StateMachineFinal(source, initial, step, nil, options...)
+}
+
+// Like StateMachine, but once the source closes, final (if not nil) is given the last state
+// and its results are sent too, as when the last open session must be flushed
+func StateMachineFinal[S any, T any, R any](source <-chan T, initial S, step func(S, T) (S, []R), final func(S) []R, options ...Option) <-chan R {
 	if source == nil {
 		return nil
 	}
-	output := make(chan T2)
+	output, ctx, done := newConfiguredStage[R](context.Background(), configure(options), source)
 	go func() {
+		defer done()
+		state := initial
+		var results []R
 		for s := range source {
-			output <- mapper(s)
+			state, results = step(state, s)
+			for _, r := range results {
+				if !send(ctx, output, r) {
+					return
+				}
+			}
+		}
+		if final != nil {
+			for _, r := range final(state) {
+				if !send(ctx, output, r) {
+					return
+				}
+			}
 		}
-		close(output)
 	}()
 	return output
 }
 
 // Applies the given mapper to elements from the two channels until one of the channels is closed
-func Zip[T1 any, T2 any, T3 any](xs chan T1, ys chan T2, mapper func(T1, T2) T3) chan T3 {
-	if xs == nil || ys == nil {
+func Zip[T1 any, T2 any, T3 any](xs <-chan T1, ys <-chan T2, mapper func(T1, T2) T3, options ...Option) <-chan T3 {
+	return ZipCtx(context.Background(), xs, ys, mapper, options...)
+}
+
+// Two values paired up from different channels
+type Pair[T1 any, T2 any] struct {
+	First  T1
+	Second T2
+}
+
+// Pairs each value received on the primary channel with the most recent value
+// received on the secondary channel, and sends the pairs on a new channel.
+// Only the primary drives sends: values arriving on the secondary just replace the latest one.
+// Primary values received before the secondary has produced anything are dropped.
+// If the secondary closes, its last value keeps being used.
+// The new channel closes when the primary does, and the secondary is then stopped.
+func WithLatestFrom[T1 any, T2 any](primary <-chan T1, secondary <-chan T2, options ...Option) <-chan Pair[T1, T2] {
+	if primary == nil {
 		return nil
 	}
-	output := make(chan T3)
+	output, ctx, done := newConfiguredStage[Pair[T1, T2]](context.Background(), configure(options), primary, secondary)
 	go func() {
+		defer done()
+		var latest T2
+		seen := false
 		for {
-			x, hasX := <-xs
-			y, hasY := <-ys
-			if !hasX || !hasY {
-				break
+			select {
+			case s, ok := <-secondary:
+				if !ok {
+					secondary = nil
+					continue
+				}
+				latest, seen = s, true
+			case p, ok := <-primary:
+				if !ok {
+					return
+				}
+				if !seen {
+					continue
+				}
+				if !send(ctx, output, Pair[T1, T2]{First: p, Second: latest}) {
+					return
+				}
+			case <-ctx.Done():
+				return
 			}
-			output <- mapper(x, y)
 		}
-		close(output)
 	}()
 	return output
 }
@@ -45,81 +227,498 @@ func Zip[T1 any, T2 any, T3 any](xs chan T1, ys chan T2, mapper func(T1, T2) T3)
 // apply the given predicate and send any results
 // where the predicate returns true on a new channel.
 // That new channel is returned.
-func Filter[T any](source chan T, predicate func(T) bool) chan T {
+func Filter[T any](source <-chan T, predicate func(T) bool, options ...Option) <-chan T {
+	return FilterCtx(context.Background(), source, predicate, options...)
+}
+
+// Receives the first n = count values from a channel and sends them on a new channel.
+// If the channel closes before n values are sent, all those values are sent.
+func Take[T any](source <-chan T, count int, options ...Option) <-chan T {
+	return TakeCtx(context.Background(), source, count, options...)
+}
+
+// Ignores the first n = count vales from a channel
+// and sends the rest (if any) on a new channel.
+func Skip[T any](source <-chan T, count int, options ...Option) <-chan T {
+	return SkipCtx(context.Background(), source, count, options...)
+}
+
+// Receives values from a channel and sends them on a new channel
+// for as long as the given predicate returns true.
+// The new channel is closed as soon as the predicate returns false.
+func TakeWhile[T any](source <-chan T, predicate func(T) bool, options ...Option) <-chan T {
+	return TakeWhileCtx(context.Background(), source, predicate, options...)
+}
+
+// Ignores values from a channel for as long as the given predicate returns true,
+// then sends the first value for which it returns false
+// and all subsequent values on a new channel.
+func SkipWhile[T any](source <-chan T, predicate func(T) bool, options ...Option) <-chan T {
+	return SkipWhileCtx(context.Background(), source, predicate, options...)
+}
+
+// Receives values from a channel and sends them on a new channel
+// until the signal channel receives a value or is closed.
+// The signal may be any receive-only channel, such as ctx.Done() or time.After(d).
+func TakeUntil[T any, S any](source <-chan T, signal <-chan S, options ...Option) <-chan T {
 	if source == nil {
 		return nil
 	}
-	output := make(chan T)
+	output, ctx, done := newConfiguredStage[T](context.Background(), configure(options), source)
 	go func() {
+		defer done()
+		for {
+			select {
+			case <-signal:
+				return
+			case <-ctx.Done():
+				return
+			case s, more := <-source:
+				if !more {
+					return
+				}
+				select {
+				case <-signal:
+					return
+				case <-ctx.Done():
+					return
+				case output <- s:
+				}
+			}
+		}
+	}()
+	return output
+}
+
+// Projects each value received on a channel to an inner channel
+// and sends the values received on the most recent inner channel on a new channel.
+// Whenever a new value arrives, the previous inner channel is stopped and abandoned,
+// so that only the latest projection is followed (as in "latest query wins").
+// The new channel closes once the source and the last inner channel have both closed.
+func SwitchMap[T1 any, T2 any](source <-chan T1, project func(T1) <-chan T2, options ...Option) <-chan T2 {
+	if source == nil {
+		return nil
+	}
+	output, ctx, done := newConfiguredStage[T2](context.Background(), configure(options), source)
+	go func() {
+		defer done()
+		var inner <-chan T2
+		defer func() { Stop(inner) }()
+		for source != nil || inner != nil {
+			select {
+			case s, ok := <-source:
+				if !ok {
+					source = nil
+					continue
+				}
+				Stop(inner)
+				inner = project(s)
+			case v, ok := <-inner:
+				if !ok {
+					inner = nil
+					continue
+				}
+				if !send(ctx, output, v) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// Mirrors whichever of the given channels sends a value first:
+// that value and every later one from the same channel are sent on a new channel,
+// and all the other channels are stopped and abandoned.
+// Channels that close without sending anything drop out of the race;
+// if they all do, the new channel closes empty.
+// Suits hedged requests, where the same data is fetched from several replicas.
+func Amb[T any](sources ...<-chan T) <-chan T {
+	upstream := make([]any, len(sources))
+	for i, source := range sources {
+		upstream[i] = source
+	}
+	output, ctx, done := newStage[T](context.Background(), upstream...)
+	type claim struct {
+		source int
+		value  T
+		ok     bool
+	}
+	race, settled := context.WithCancel(ctx)
+	claims := make(chan claim)
+	for i, source := range sources {
+		go func() {
+			s, ok := receive(race, source)
+			send(race, claims, claim{source: i, value: s, ok: ok})
+		}()
+	}
+	go func() {
+		defer done()
+		defer settled()
+		for range sources {
+			c, ok := receive(ctx, claims)
+			if !ok {
+				return
+			}
+			if !c.ok {
+				continue
+			}
+			settled()
+			for i, source := range sources {
+				if i != c.source {
+					Stop(source)
+				}
+			}
+			if !send(ctx, output, c.value) {
+				return
+			}
+			for s := range sources[c.source] {
+				if !send(ctx, output, s) {
+					return
+				}
+			}
+			return
+		}
+	}()
+	return output
+}
+
+// Receives all values from a channel and, once it closes,
+// sends the last n = count values on a new channel.
+// Only the last n values are held in memory at any time.
+// Respects WithMemoryBudget.
+func TakeLast[T any](source <-chan T, count int, options ...Option) <-chan T {
+	if source == nil {
+		return nil
+	}
+	config := configure(options)
+	output, ctx, done := newConfiguredStage[T](context.Background(), config, source)
+	go func() {
+		defer done()
+		memory := config.memoryAccount()
+		defer memory.releaseAll()
+		last := newRing[T](count)
 		for s := range source {
-			if predicate(s) {
-				output <- s
+			if !memory.hold(s) {
+				return
+			}
+			if evicted, full := last.push(s); full {
+				memory.release(evicted)
+			}
+		}
+		for _, s := range last.values() {
+			if !send(ctx, output, s) {
+				return
 			}
+			memory.release(s)
 		}
-		close(output)
 	}()
 	return output
 }
 
-// Receives the first n = count values from a channel and sends them on a new channel.
-// If the channel closes before n values are sent, all those values are sent.
-func Take[T any](source chan T, count int) chan T {
-	output := make(chan T)
-	taken := 0
-	go func() chan T {
+// Sends all but the last n = count values from a channel on a new channel.
+// Each value is sent once n more values have been received after it.
+// Respects WithMemoryBudget.
+func SkipLast[T any](source <-chan T, count int, options ...Option) <-chan T {
+	if source == nil {
+		return nil
+	}
+	config := configure(options)
+	output, ctx, done := newConfiguredStage[T](context.Background(), config, source)
+	go func() {
+		defer done()
+		memory := config.memoryAccount()
+		defer memory.releaseAll()
+		pending := newRing[T](count)
 		for s := range source {
-			taken++
-			if taken > count {
-				break
+			if !memory.hold(s) {
+				return
+			}
+			if evicted, full := pending.push(s); full {
+				if !send(ctx, output, evicted) {
+					return
+				}
+				memory.release(evicted)
 			}
-			output <- s
 		}
-		close(output)
-		return output
 	}()
 	return output
 }
 
-// Ignores the first n = count vales from a channel
-// and sends the rest (if any) on a new channel.
-func Skip[T any](source chan T, count int) chan T {
-	output := make(chan T)
-	skipped := 0
+// Receives all values from a channel and, once it closes,
+// sends them on a new channel in reverse order.
+// Respects WithMemoryBudget.
+func Reverse[T any](source <-chan T, options ...Option) <-chan T {
+	if source == nil {
+		return nil
+	}
+	config := configure(options)
+	output, ctx, done := newConfiguredStage[T](context.Background(), config, source)
+	go func() {
+		defer done()
+		memory := config.memoryAccount()
+		defer memory.releaseAll()
+		var buffered []T
+		for s := range source {
+			if !memory.hold(s) {
+				return
+			}
+			buffered = append(buffered, s)
+		}
+		for i := len(buffered) - 1; i >= 0; i-- {
+			if !send(ctx, output, buffered[i]) {
+				return
+			}
+			memory.release(buffered[i])
+		}
+	}()
+	return output
+}
+
+// Sends all values from a channel on a new channel,
+// followed by the given values once the source closes
+func Append[T any](source <-chan T, values ...T) <-chan T {
+	if source == nil {
+		return nil
+	}
+	output, ctx, done := newStage[T](context.Background(), source)
+	go func() {
+		defer done()
+		for s := range source {
+			if !send(ctx, output, s) {
+				return
+			}
+		}
+		for _, v := range values {
+			if !send(ctx, output, v) {
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// Sends the given values on a new channel,
+// followed by all values from the source channel
+func Prepend[T any](source <-chan T, values ...T) <-chan T {
+	if source == nil {
+		return nil
+	}
+	output, ctx, done := newStage[T](context.Background(), source)
+	go func() {
+		defer done()
+		for _, v := range values {
+			if !send(ctx, output, v) {
+				return
+			}
+		}
+		for s := range source {
+			if !send(ctx, output, s) {
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// Sends all values from a channel on a new channel.
+// If the source closes without sending anything,
+// the given default value is sent instead.
+func DefaultIfEmpty[T any](source <-chan T, defaultValue T, options ...Option) <-chan T {
+	if source == nil {
+		return nil
+	}
+	output, ctx, done := newConfiguredStage[T](context.Background(), configure(options), source)
+	go func() {
+		defer done()
+		empty := true
+		for s := range source {
+			empty = false
+			if !send(ctx, output, s) {
+				return
+			}
+		}
+		if empty {
+			send(ctx, output, defaultValue)
+		}
+	}()
+	return output
+}
+
+// Sends all values from a channel on a new channel unchanged,
+// calling observe with each one before it is sent,
+// for logging, metrics, or debugging without disturbing the pipeline
+func Tap[T any](source <-chan T, observe func(T), options ...Option) <-chan T {
+	if source == nil {
+		return nil
+	}
+	output, ctx, done := newConfiguredStage[T](context.Background(), configure(options), source)
 	go func() {
+		defer done()
 		for s := range source {
-			skipped++
-			if skipped > count {
-				output <- s
+			observe(s)
+			if !send(ctx, output, s) {
+				return
 			}
 		}
-		close(output)
 	}()
 	return output
 }
 
+// Like Tap, but calls observe for at most n = maxPerSecond values in each second,
+// so that observability hooks can run in hot pipelines without becoming the bottleneck.
+// Values are sampled at random with a probability chosen from the rate of the previous second,
+// so the observed ones are spread evenly rather than bunched at the start of each second
+// (except in the first second, when there is no rate to go by yet).
+func TapSampled[T any](source <-chan T, maxPerSecond int, observe func(T), options ...Option) <-chan T {
+	start := time.Now()
+	seen, observed, previous := 0, 0, 0
+	return Tap(source, func(s T) {
+		if now := time.Now(); now.Sub(start) >= time.Second {
+			start, previous, seen, observed = now, seen, 0, 0
+		}
+		seen++
+		if observed >= maxPerSecond {
+			return
+		}
+		if previous > maxPerSecond && rand.IntN(previous) >= maxPerSecond {
+			return
+		}
+		observed++
+		observe(s)
+	}, options...)
+}
+
 // Aggregation functions
 
-// Returns the maximum element received on the given channel
-func Max[T cmp.Ordered](source chan T) T {
+// Returns the maximum element received on the given channel,
+// or the zero value if the channel closes without sending anything.
+// For a pipeline in batch mode, MaxBatches avoids a receive per value.
+func Max[T cmp.Ordered](source <-chan T) T {
+	max, _ := MaxOk(source)
+	return max
+}
+
+// Returns the maximum element received on the given channel and true,
+// or the zero value and false if the channel closes without sending anything
+func MaxOk[T cmp.Ordered](source <-chan T) (T, bool) {
 	var max T
-	first := true
+	found := false
 	for s := range source {
-		if first || s > max {
+		if !found || s > max {
 			max = s
 		}
-		first = false
+		found = true
 	}
-	return max
+	return max, found
 }
 
-// Returns the first element received on the given channel
-func First[T any](source chan T) T {
+// Returns the minimum element received on the given channel,
+// or the zero value if the channel closes without sending anything.
+// For a pipeline in batch mode, MinBatches avoids a receive per value.
+func Min[T cmp.Ordered](source <-chan T) T {
+	min, _ := MinOk(source)
+	return min
+}
+
+// Returns the minimum element received on the given channel and true,
+// or the zero value and false if the channel closes without sending anything
+func MinOk[T cmp.Ordered](source <-chan T) (T, bool) {
+	var min T
+	found := false
+	for s := range source {
+		if !found || s < min {
+			min = s
+		}
+		found = true
+	}
+	return min, found
+}
+
+// Returns the first element received on the given channel,
+// or the zero value if the channel closes without sending anything
+func First[T any](source <-chan T) T {
 	first := <-source
+	Stop(source)
 	return first
 }
 
-// Returns the Last element received on the given channel
-func Last[T any](source chan T) T {
+// Returns the first element received on the given channel and true,
+// or the zero value and false if the channel closes without sending anything.
+// Like First, it waits for as long as the channel is open and empty.
+func FirstOk[T any](source <-chan T) (T, bool) {
+	first, ok := <-source
+	Stop(source)
+	return first, ok
+}
+
+// Returns the first element received on the given channel
+// that matches the given predicate and true,
+// or the zero value and false if the channel closes first.
+// Receives nothing further once a match is found.
+func FirstOkFunc[T any](source <-chan T, predicate func(T) bool) (T, bool) {
+	for s := range source {
+		if predicate(s) {
+			Stop(source)
+			return s, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// Returns the last element received on the given channel and true,
+// or the zero value and false if the channel closes without sending anything
+func LastOk[T any](source <-chan T) (T, bool) {
+	var last T
+	found := false
+	for s := range source {
+		last = s
+		found = true
+	}
+	return last, found
+}
+
+// Returns the last element received on the given channel
+// that matches the given predicate and true,
+// or the zero value and false if there is no such element
+func LastOkFunc[T any](source <-chan T, predicate func(T) bool) (T, bool) {
+	var last T
+	found := false
+	for s := range source {
+		if predicate(s) {
+			last = s
+			found = true
+		}
+	}
+	return last, found
+}
+
+// Returns the element at the given zero-based index of the given channel
+// and true, receiving nothing further once it is found.
+// If the channel closes first, or the index is negative,
+// returns the zero value and false.
+func ElementAt[T any](source <-chan T, index int) (T, bool) {
+	var zero T
+	if index < 0 {
+		return zero, false
+	}
+	i := 0
+	for s := range source {
+		if i == index {
+			Stop(source)
+			return s, true
+		}
+		i++
+	}
+	return zero, false
+}
+
+// Returns the Last element received on the given channel,
+// or the zero value if the channel closes without sending anything
+func Last[T any](source <-chan T) T {
 	var last T
 	for s := range source {
 		last = s
@@ -127,9 +726,19 @@ func Last[T any](source chan T) T {
 	return last
 }
 
+// Listens on a channel until the channel is closed,
+// and return the elements received in a slice
+func ToSlice[T any](source <-chan T) []T {
+	var elements []T
+	for s := range source {
+		elements = append(elements, s)
+	}
+	return elements
+}
+
 // Listens on a channel until the channel is closed,
 // and return the number of elements received
-func Count[T any](source chan T) int {
+func Count[T any](source <-chan T) int {
 	count := 0
 	for {
 		_, more := <-source
@@ -140,44 +749,144 @@ func Count[T any](source chan T) int {
 	}
 }
 
-// Given a channel of numeric values, return their Sum
-func Sum[T float32 | float64 | int | int32 | int64](source chan T) T {
+// Listens on a channel until the channel is closed,
+// and return the number of elements received that match the given predicate
+func CountIf[T any](source <-chan T, predicate func(T) bool) int {
+	count := 0
+	for s := range source {
+		if predicate(s) {
+			count++
+		}
+	}
+	return count
+}
+
+// Receives from two channels in lockstep and reports whether
+// they send equal values in the same order and close at the same time.
+// Stops receiving as soon as a difference is found.
+func SequenceEqual[T comparable](a <-chan T, b <-chan T) bool {
+	return SequenceEqualFunc(a, b, func(x T, y T) bool { return x == y })
+}
+
+// Like SequenceEqual, but compares values with the given equality function
+func SequenceEqualFunc[T any](a <-chan T, b <-chan T, equal func(T, T) bool) bool {
+	defer Stop(a)
+	defer Stop(b)
+	for {
+		x, hasX := <-a
+		y, hasY := <-b
+		if hasX != hasY {
+			return false
+		}
+		if !hasX {
+			return true
+		}
+		if !equal(x, y) {
+			return false
+		}
+	}
+}
+
+// The numeric types that the arithmetic aggregations accept
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+// Given a channel of numeric values, return their Sum.
+// For a pipeline in batch mode, SumBatches avoids a receive per value.
+func Sum[T Number](source <-chan T) T {
+	sum, _ := SumOk(source)
+	return sum
+}
+
+// Given a channel of numeric values, return their Sum and true,
+// or zero and false if the channel closes without sending anything,
+// so that an empty channel can be told apart from values that sum to zero
+func SumOk[T Number](source <-chan T) (T, bool) {
 	var ret T
-	ret = 0
+	found := false
 	for s := range source {
 		ret += s
+		found = true
+	}
+	return ret, found
+}
+
+// Given a channel of numeric values, return their mean and true,
+// or zero and false if the channel closes without sending anything.
+// For a pipeline in batch mode, AverageBatches avoids a receive per value.
+func AverageOk[T Number](source <-chan T) (float64, bool) {
+	var total float64
+	count := 0
+	for s := range source {
+		total += float64(s)
+		count++
+	}
+	if count == 0 {
+		return 0, false
 	}
-	return ret
+	return total / float64(count), true
 }
 
 // Create a channel and send each element
 // of the given array on that channel.
 // After closing the channel, return it
-func From[T any](source []T) chan T {
-	output := make(chan T)
-	go func() {
-		for _, elem := range source {
-			output <- elem
-		}
-		close(output)
-	}()
-	return output
+func From[T any](source []T, options ...Option) <-chan T {
+	return FromCtx(context.Background(), source, options...)
 }
 
-// Output all the Fibonacci numbers onto a channel
-func Fibonaccis() chan int {
-	output := make(chan int)
-	a := 1
-	b := 1
-	go func() {
-		output <- a
-		output <- b
-		for {
-			c := a + b
-			output <- c
-			a = b
-			b = c
-		}
-	}()
-	return output
+// Create a channel and send the n = count consecutive integers
+// beginning with start on that channel, then close it
+func Range(start int, count int, options ...Option) <-chan int {
+	return RangeCtx(context.Background(), start, count, options...)
+}
+
+// Create a channel and send the given value on it
+// n = count times, then close it
+func Repeat[T any](value T, count int, options ...Option) <-chan T {
+	return RepeatCtx(context.Background(), value, count, options...)
+}
+
+// Create a channel and lazily send on it the values produced
+// by repeatedly applying next to a state, beginning with the seed.
+// Each call to next returns a value to send, the following state,
+// and whether there is a value at all; the channel is closed
+// the first time next reports that there is not.
+func Generate[S any, T any](seed S, next func(S) (T, S, bool), options ...Option) <-chan T {
+	return GenerateCtx(context.Background(), seed, next, options...)
+}
+
+// Create a channel and send each entry of the given map on it as a KeyValue,
+// in no particular order, then close it
+func FromMap[K comparable, V any](source map[K]V, options ...Option) <-chan KeyValue[K, V] {
+	return FromMapCtx(context.Background(), source, options...)
+}
+
+// Create a channel and send each rune of the given string on it, then close it
+func FromString(source string, options ...Option) <-chan rune {
+	return FromStringCtx(context.Background(), source, options...)
+}
+
+// Create a channel and send the values returned by repeated calls to f on it,
+// closing it the first time f reports that there is no value
+func FromFunc[T any](f func() (T, bool), options ...Option) <-chan T {
+	return FromFuncCtx(context.Background(), f, options...)
+}
+
+// Create a channel and send the items of successive pages on it as successful Results,
+// fetching each page (starting with an empty page token) only once the consumer
+// has received everything from the one before, as with a paginated API listing.
+// The channel is closed after the page whose next token is empty,
+// or after a failed Result if a fetch fails.
+func FromPages[T any](fetch func(pageToken string) (items []T, next string, err error), options ...Option) <-chan Result[T] {
+	return FromPagesCtx(context.Background(), fetch, options...)
+}
+
+// Output all the Fibonacci numbers onto a channel.
+// The channel never closes on its own; stop it with Stop,
+// or use FibonaccisCtx and cancel the context.
+func Fibonaccis(options ...Option) <-chan int {
+	return FibonaccisCtx(context.Background(), options...)
 }
@@ -0,0 +1,89 @@
+package gl
+
+// A tree of groups built by GroupByMulti.
+// Each level is keyed by one key selector;
+// the elements themselves are kept at the leaves.
+type NestedGroups[T any] struct {
+	// Child groups in the order their keys were first seen
+	Keys   []string
+	Groups map[string]*NestedGroups[T]
+	// The elements in this group; only set on leaves
+	Items []T
+
+	memory *memoryAccount // charged for every element until Release; only set on the root
+	err    error
+}
+
+// Receives every element from a channel and groups it by each of the given
+// key selectors in turn, producing a tree with one level per selector.
+// With no selectors, all elements end up in the root's Items.
+// With WithMemoryBudget, the elements are held within the budget for as long as
+// the groups are kept, until Release is called; if it is exceeded, the channel is stopped
+// and the groups built so far are returned, with Err reporting that they are incomplete.
+func GroupByMulti[T any](source <-chan T, keys []func(T) string, options ...Option) NestedGroups[T] {
+	memory := configure(options).memoryAccount()
+	root := NestedGroups[T]{memory: memory}
+	for s := range source {
+		if !memory.hold(s) {
+			Stop(source)
+			root.err = ErrMemoryBudgetExceeded
+			break
+		}
+		node := &root
+		for _, key := range keys {
+			node = node.child(key(s))
+		}
+		node.Items = append(node.Items, s)
+	}
+	return root
+}
+
+// Returns ErrMemoryBudgetExceeded if GroupByMulti stopped early,
+// leaving the groups incomplete, or nil
+func (g *NestedGroups[T]) Err() error {
+	return g.err
+}
+
+// Gives the memory held for the elements back to the budget given to GroupByMulti,
+// once the groups are no longer needed. Does nothing without a budget.
+func (g *NestedGroups[T]) Release() {
+	g.memory.releaseAll()
+	g.memory = nil
+}
+
+// Returns the child group with the given key, creating it if needed
+func (g *NestedGroups[T]) child(key string) *NestedGroups[T] {
+	if g.Groups == nil {
+		g.Groups = make(map[string]*NestedGroups[T])
+	}
+	child, ok := g.Groups[key]
+	if !ok {
+		child = &NestedGroups[T]{}
+		g.Groups[key] = child
+		g.Keys = append(g.Keys, key)
+	}
+	return child
+}
+
+// Returns the group reached by following the given keys from this one,
+// or nil if there is no such group
+func (g *NestedGroups[T]) Get(path ...string) *NestedGroups[T] {
+	node := g
+	for _, key := range path {
+		child, ok := node.Groups[key]
+		if !ok {
+			return nil
+		}
+		node = child
+	}
+	return node
+}
+
+// Returns the number of elements in this group and all groups below it
+func (g *NestedGroups[T]) Count() int {
+	count := len(g.Items)
+	for _, child := range g.Groups {
+		count += child.Count()
+	}
+	return count
+}
@@ -0,0 +1,117 @@
+package gl
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+)
+
+// A function that can be replaced while the stages using it are running,
+// so that rules (a filter predicate, an enrichment mapper) can be updated
+// without restarting a long-lived pipeline.
+// Use it with MapHotSwap or FilterHotSwap, or call it directly from any other stage.
+type HotSwap[T1 any, T2 any] struct {
+	mu      sync.RWMutex
+	current *hotSwapFunc[T1, T2]
+	retired []*hotSwapFunc[T1, T2] // replaced, with calls still in flight
+}
+
+// One function held by a HotSwap, and the calls to it in flight
+type hotSwapFunc[T1 any, T2 any] struct {
+	f       func(T1) T2
+	calls   sync.WaitGroup
+	drained chan struct{} // closed once it is replaced and its calls have finished
+}
+
+// Creates a HotSwap holding the given function
+func NewHotSwap[T1 any, T2 any](f func(T1) T2) *HotSwap[T1, T2] {
+	return &HotSwap[T1, T2]{current: &hotSwapFunc[T1, T2]{f: f, drained: make(chan struct{})}}
+}
+
+// Applies the current function to the given value.
+// The function runs without any lock held, so it may itself call Swap.
+func (h *HotSwap[T1, T2]) Call(value T1) T2 {
+	h.mu.RLock()
+	current := h.current
+	current.calls.Add(1)
+	h.mu.RUnlock()
+	defer current.calls.Done()
+	return current.f(value)
+}
+
+// Replaces the function with the given one and returns the old one.
+// Every call made after Swap returns uses the new function,
+// while calls to the old one that are in flight finish with it.
+// Swap does not wait for them, so it may be called from within the function itself;
+// use Drain to wait for the handoff to complete.
+func (h *HotSwap[T1, T2]) Swap(f func(T1) T2) func(T1) T2 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	old := h.current
+	h.current = &hotSwapFunc[T1, T2]{f: f, drained: make(chan struct{})}
+	h.retired = append(h.retired, old)
+	// No call can start on old from here on, so its count only goes down
+	go func() {
+		old.calls.Wait()
+		h.mu.Lock()
+		h.retired = slices.DeleteFunc(h.retired, func(r *hotSwapFunc[T1, T2]) bool { return r == old })
+		h.mu.Unlock()
+		close(old.drained)
+	}()
+	return old.f
+}
+
+// Waits until every call to a function replaced so far has finished,
+// after which every value goes through the current function.
+// Calling Drain from within a replaced function waits forever.
+func (h *HotSwap[T1, T2]) Drain() {
+	h.mu.RLock()
+	retired := slices.Clone(h.retired)
+	h.mu.RUnlock()
+	for _, r := range retired {
+		<-r.drained
+	}
+}
+
+// Applies the current function of the given HotSwap to each value received on a channel
+// and sends the result on a new channel
+func MapHotSwap[T1 any, T2 any](source <-chan T1, h *HotSwap[T1, T2], options ...Option) <-chan T2 {
+	return Map(source, h.Call, options...)
+}
+
+// Sends the values received on a channel for which the current predicate
+// of the given HotSwap returns true on a new channel
+func FilterHotSwap[T any](source <-chan T, h *HotSwap[T, bool], options ...Option) <-chan T {
+	return Filter(source, h.Call, options...)
+}
+
+// Registers a stage template under the given name that maps each value
+// with the current function of the given HotSwap, so that the stage can be replaced
+// by name with SwapStage in every pipeline assembled from the registry.
+// Returns an error if the name is already taken.
+func RegisterHotSwap[T1 any, T2 any](r *StageRegistry, name string, h *HotSwap[T1, T2]) error {
+	return registerStage(r, name, func(Params) (Stage[T1, T2], error) {
+		return func(source <-chan T1) <-chan T2 {
+			return MapHotSwap(source, h)
+		}, nil
+	}, h)
+}
+
+// Replaces the function of the stage registered under the given name with RegisterHotSwap,
+// handing off as HotSwap.Swap does, and returns the old function.
+// Returns an error if there is no such stage or it does not take T1 to T2.
+func SwapStage[T1 any, T2 any](r *StageRegistry, name string, f func(T1) T2) (func(T1) T2, error) {
+	t, err := r.Template(name)
+	if err != nil {
+		return nil, err
+	}
+	if t.swap == nil {
+		return nil, fmt.Errorf("gl: stage %q was not registered with RegisterHotSwap", name)
+	}
+	h, ok := t.swap.(*HotSwap[T1, T2])
+	if !ok {
+		return nil, fmt.Errorf("%w: stage %q takes %v to %v, not %v to %v", ErrStageType, name, t.In, t.Out, reflect.TypeFor[T1](), reflect.TypeFor[T2]())
+	}
+	return h.Swap(f), nil
+}
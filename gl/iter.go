@@ -0,0 +1,78 @@
+package gl
+
+import (
+	"context"
+	"iter"
+)
+
+// A pair of values, as produced by an iter.Seq2 or a map
+type KeyValue[K any, V any] struct {
+	Key   K
+	Value V
+}
+
+// Create a channel and send each value of the given iterator on it,
+// then close it. The iterator is abandoned if the channel is stopped.
+func FromSeq[T any](seq iter.Seq[T], options ...Option) <-chan T {
+	return FromSeqCtx(context.Background(), seq, options...)
+}
+
+// Context-aware FromSeq
+func FromSeqCtx[T any](ctx context.Context, seq iter.Seq[T], options ...Option) <-chan T {
+	output, ctx, done := newConfiguredStage[T](ctx, configure(options))
+	go func() {
+		defer done()
+		for value := range seq {
+			if !send(ctx, output, value) {
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// Create a channel and send each pair of the given iterator on it as a KeyValue,
+// then close it. The iterator is abandoned if the channel is stopped.
+func FromSeq2[K any, V any](seq iter.Seq2[K, V], options ...Option) <-chan KeyValue[K, V] {
+	return FromSeq2Ctx(context.Background(), seq, options...)
+}
+
+// Context-aware FromSeq2
+func FromSeq2Ctx[K any, V any](ctx context.Context, seq iter.Seq2[K, V], options ...Option) <-chan KeyValue[K, V] {
+	output, ctx, done := newConfiguredStage[KeyValue[K, V]](ctx, configure(options))
+	go func() {
+		defer done()
+		for key, value := range seq {
+			if !send(ctx, output, KeyValue[K, V]{Key: key, Value: value}) {
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// Returns an iterator over the values received on a channel, for use with range.
+// Breaking out of the loop early stops the channel.
+func ToSeq[T any](source <-chan T) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for s := range source {
+			if !yield(s) {
+				Stop(source)
+				return
+			}
+		}
+	}
+}
+
+// Returns an iterator over the pairs received on a channel, for use with range.
+// Breaking out of the loop early stops the channel.
+func ToSeq2[K any, V any](source <-chan KeyValue[K, V]) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for s := range source {
+			if !yield(s.Key, s.Value) {
+				Stop(source)
+				return
+			}
+		}
+	}
+}
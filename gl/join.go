@@ -0,0 +1,84 @@
+package gl
+
+import (
+	"cmp"
+	"context"
+)
+
+// Join operators
+
+// Joins two channels that are each already sorted by key in ascending order,
+// sending the result of combining each left value with each right value
+// that has an equal key (an inner join).
+// Only the right values sharing the current key are held in memory,
+// so neither side needs to be buffered in full.
+func MergeJoin[L any, R any, K cmp.Ordered, O any](left <-chan L, right <-chan R, leftKey func(L) K, rightKey func(R) K, result func(L, R) O, options ...Option) <-chan O {
+	if left == nil || right == nil {
+		return nil
+	}
+	output, ctx, done := newConfiguredStage[O](context.Background(), configure(options), left, right)
+	go func() {
+		defer done()
+		l, hasL := <-left
+		r, hasR := <-right
+		for hasL && hasR {
+			lk, rk := leftKey(l), rightKey(r)
+			switch {
+			case lk < rk:
+				l, hasL = <-left
+			case lk > rk:
+				r, hasR = <-right
+			default:
+				group := []R{r}
+				for r, hasR = <-right; hasR && rightKey(r) == lk; r, hasR = <-right {
+					group = append(group, r)
+				}
+				for ; hasL && leftKey(l) == lk; l, hasL = <-left {
+					for _, g := range group {
+						if !send(ctx, output, result(l, g)) {
+							return
+						}
+					}
+				}
+			}
+		}
+	}()
+	return output
+}
+
+// Receives every value from the right channel,
+// then sends each value from the left channel whose key
+// matches the key of at least one right value
+func SemiJoin[L any, R any, K comparable](left <-chan L, right <-chan R, leftKey func(L) K, rightKey func(R) K) <-chan L {
+	return keyMembershipFilter(left, right, leftKey, rightKey, true)
+}
+
+// Receives every value from the right channel,
+// then sends each value from the left channel whose key
+// matches the key of no right value
+func AntiJoin[L any, R any, K comparable](left <-chan L, right <-chan R, leftKey func(L) K, rightKey func(R) K) <-chan L {
+	return keyMembershipFilter(left, right, leftKey, rightKey, false)
+}
+
+// Sends the values of left whose key's membership among the keys of right is as given
+func keyMembershipFilter[L any, R any, K comparable](left <-chan L, right <-chan R, leftKey func(L) K, rightKey func(R) K, member bool) <-chan L {
+	if left == nil || right == nil {
+		return nil
+	}
+	output, ctx, done := newStage[L](context.Background(), left, right)
+	go func() {
+		defer done()
+		keys := make(map[K]struct{})
+		for r := range right {
+			keys[rightKey(r)] = struct{}{}
+		}
+		for l := range left {
+			if _, in := keys[leftKey(l)]; in == member {
+				if !send(ctx, output, l) {
+					return
+				}
+			}
+		}
+	}()
+	return output
+}
@@ -0,0 +1,63 @@
+package gl
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Reads JSON Lines (newline-delimited JSON) from the given reader,
+// decodes each line into a value of type T, and sends them on a new channel
+// as successful Results. Each line is decoded on its own, so a malformed line
+// is sent as a failed Result and reading carries on; blank lines are skipped.
+// The channel closes at the end of the input,
+// or after a failed Result for an error from the reader itself.
+func FromJSONLines[T any](r io.Reader) <-chan Result[T] {
+	return FromJSONLinesCtx[T](context.Background(), r)
+}
+
+// Context-aware FromJSONLines
+func FromJSONLinesCtx[T any](ctx context.Context, r io.Reader) <-chan Result[T] {
+	output, ctx, done := newStage[Result[T]](ctx)
+	go func() {
+		defer done()
+		reader := bufio.NewReader(r)
+		for number := 1; ; number++ {
+			line, err := reader.ReadBytes('\n')
+			if err != nil && err != io.EOF {
+				send(ctx, output, Fail[T](fmt.Errorf("gl: reading json lines: %w", err)))
+				return
+			}
+			if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+				var result Result[T]
+				if decodeErr := json.Unmarshal(trimmed, &result.Value); decodeErr != nil {
+					result = Fail[T](fmt.Errorf("gl: json lines line %d: %w", number, decodeErr))
+				}
+				if !send(ctx, output, result) {
+					return
+				}
+			}
+			if err == io.EOF {
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// Listens on a channel until it is closed and writes each value received to w
+// as a line of JSON, one write per value, so a large stream is never buffered.
+// If encoding or writing fails, the channel is stopped and the error returned.
+func ToJSONLines[T any](source <-chan T, w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	for s := range source {
+		if err := encoder.Encode(s); err != nil {
+			Stop(source)
+			return fmt.Errorf("gl: writing json lines: %w", err)
+		}
+	}
+	return nil
+}
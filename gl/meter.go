@@ -0,0 +1,61 @@
+package gl
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// What MeterBytes does once its quota is exceeded
+type QuotaPolicy struct {
+	// Close the stream instead of sending the chunk that would exceed the quota
+	HardStop bool
+	// Otherwise, the most bytes per second to let through once over the quota;
+	// zero or less lets them through at full speed
+	ThrottleRate int64
+}
+
+// Forwards the chunks of bytes received on a channel, counting the bytes sent,
+// for pipelines whose downstream billing or storage is limited by bytes.
+// Once the total would exceed the given quota, onExceed (if not nil) is called once
+// and the policy applies: the stream is stopped before that chunk,
+// or it carries on, throttled or not.
+// Also returns a counter of the bytes sent so far.
+func MeterBytes(source <-chan []byte, quota int64, onExceed func(), policy QuotaPolicy, options ...Option) (<-chan []byte, *atomic.Int64) {
+	sent := new(atomic.Int64)
+	if source == nil {
+		return nil, sent
+	}
+	output, ctx, done := newConfiguredStage[[]byte](context.Background(), configure(options), source)
+	go func() {
+		defer done()
+		var over int64 // bytes sent past the quota
+		var overSince time.Time
+		for s := range source {
+			total := sent.Load() + int64(len(s))
+			if total > quota {
+				if overSince.IsZero() {
+					overSince = time.Now()
+					if onExceed != nil {
+						onExceed()
+					}
+				}
+				if policy.HardStop {
+					return
+				}
+				if policy.ThrottleRate > 0 {
+					due := overSince.Add(time.Duration(float64(over) / float64(policy.ThrottleRate) * float64(time.Second)))
+					if !sleep(ctx, time.Until(due)) {
+						return
+					}
+				}
+				over += min(int64(len(s)), total-quota)
+			}
+			if !send(ctx, output, s) {
+				return
+			}
+			sent.Add(int64(len(s)))
+		}
+	}()
+	return output, sent
+}
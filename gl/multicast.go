@@ -0,0 +1,323 @@
+package gl
+
+import (
+	"slices"
+	"sync"
+)
+
+// What a multicast does when a subscriber's buffer is full
+type OverflowPolicy int
+
+const (
+	// The producer waits until the slow subscriber catches up,
+	// holding up every other subscriber too
+	OverflowBlock OverflowPolicy = iota
+	// Values are dropped for the slow subscriber only
+	OverflowDrop
+	// The slow subscriber's channel is closed once its buffered values are delivered
+	OverflowDisconnect
+)
+
+// Per-subscriber configuration for multicast operators.
+// A Buffer of zero or less means the subscriber's buffer is unbounded,
+// in which case the Overflow policy never applies.
+type SubscriberConfig struct {
+	Buffer   int
+	Overflow OverflowPolicy
+}
+
+// One consumer of a multicast stream.
+// Values offered to a subscriber are queued and delivered
+// on its output channel by a dedicated goroutine,
+// so a slow subscriber does not hold up the producer
+// unless its buffer is full and its policy says to block.
+type subscriber[T any] struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	config   SubscriberConfig
+	queue    []T
+	finished bool
+	output   chan T
+	stopped  chan struct{}
+}
+
+// Creates a subscriber whose output channel will first receive the given values.
+// If released is not nil, it is called when the subscriber is stopped from downstream.
+func newSubscriber[T any](initial []T, config SubscriberConfig, released func()) *subscriber[T] {
+	s := &subscriber[T]{queue: initial, config: config, output: NewBuffered[T](0), stopped: make(chan struct{})}
+	s.cond = sync.NewCond(&s.mu)
+	onStop(s.output, func() {
+		s.stop()
+		if released != nil {
+			released()
+		}
+	})
+	go s.run()
+	return s
+}
+
+// Queues a value for delivery, applying the overflow policy if the buffer is full.
+// Returns false if the subscriber has been disconnected.
+func (s *subscriber[T]) offer(value T) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.finished {
+		return false
+	}
+	if s.config.Buffer > 0 {
+		for len(s.queue) >= s.config.Buffer {
+			switch s.config.Overflow {
+			case OverflowDrop:
+				return true
+			case OverflowDisconnect:
+				s.finished = true
+				s.cond.Broadcast()
+				return false
+			default:
+				s.cond.Wait()
+				// The subscriber may have been stopped while we waited
+				if s.finished {
+					return false
+				}
+			}
+		}
+	}
+	s.queue = append(s.queue, value)
+	s.cond.Broadcast()
+	return true
+}
+
+// Closes the output channel once all queued values have been delivered
+func (s *subscriber[T]) finish() {
+	s.mu.Lock()
+	s.finished = true
+	s.mu.Unlock()
+	s.cond.Broadcast()
+}
+
+// Disconnects the subscriber at once, discarding any queued values
+func (s *subscriber[T]) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.finished = true
+	s.queue = nil
+	select {
+	case <-s.stopped:
+	default:
+		close(s.stopped)
+	}
+	s.cond.Broadcast()
+}
+
+func (s *subscriber[T]) run() {
+	defer forgetStop(s.output)
+	defer close(s.output)
+	for {
+		s.mu.Lock()
+		for len(s.queue) == 0 && !s.finished {
+			s.cond.Wait()
+		}
+		if len(s.queue) == 0 {
+			s.mu.Unlock()
+			return
+		}
+		value := s.queue[0]
+		s.queue = s.queue[1:]
+		s.cond.Broadcast()
+		s.mu.Unlock()
+		select {
+		case s.output <- value:
+		case <-s.stopped:
+			return
+		}
+	}
+}
+
+// A Replayable receives values from a source channel once
+// and lets any number of subscribers receive them,
+// each new subscriber first receiving the most recent values
+type Replayable[T any] struct {
+	mu          sync.Mutex
+	history     *ring[T]
+	subscribers []*subscriber[T]
+	done        bool
+	// Set when no subscribers can be added after the first value,
+	// in which case the source is stopped once every subscriber has disconnected
+	fixed bool
+	// Set by Replay: the source is only received from once there is a subscriber,
+	// and is stopped once every subscriber has disconnected
+	refCount bool
+	source   <-chan T
+	started  bool
+	// The configuration used by Subscribe
+	defaults SubscriberConfig
+	// The budget the kept values are held within, if any
+	memory *memoryAccount
+}
+
+// Starts receiving values from a channel, keeping the last n = count values.
+// Subscribers to the returned Replayable immediately receive those values
+// followed by all values received afterwards.
+// With WithMemoryBudget, the kept values are held within the budget
+// for as long as they are kept; exceeding it stops the source.
+func ReplayLast[T any](source <-chan T, count int, options ...Option) *Replayable[T] {
+	if source == nil {
+		return nil
+	}
+	r := &Replayable[T]{history: newRing[T](count), memory: configure(options).memoryAccount()}
+	go r.pump(source)
+	return r
+}
+
+// Starts receiving values from a channel and lets any number of subscribers
+// receive the values sent after they subscribe
+func Share[T any](source <-chan T) *Replayable[T] {
+	return ReplayLast(source, 0)
+}
+
+// Returns one channel per given configuration, each of which receives
+// every value from the source channel, subject to its buffer and overflow policy.
+// All channels are subscribed before the first value is received,
+// so none of them miss any values.
+func Tee[T any](source <-chan T, configs ...SubscriberConfig) []<-chan T {
+	if source == nil {
+		return nil
+	}
+	r := &Replayable[T]{history: newRing[T](0), fixed: true}
+	outputs := make([]<-chan T, len(configs))
+	for i, config := range configs {
+		outputs[i] = r.SubscribeWith(config)
+	}
+	go r.pump(source)
+	return outputs
+}
+
+// Records the values received from a channel, starting once the first subscriber
+// subscribes, and lets any number of subscribers each receive all of them
+// on a channel of their own, however late they subscribe.
+// Only the last n = bufferSize values are kept for late subscribers;
+// a bufferSize of zero or less keeps them all.
+// Subscribe waits for the slowest subscriber rather than queueing without bound,
+// so an infinite source only runs as far ahead as its subscribers.
+// Once every subscriber has been stopped, the source is stopped too;
+// subscribers arriving after that receive the kept values and nothing more.
+// With WithMemoryBudget, the kept values are held within the budget
+// for as long as they are kept; exceeding it stops the source.
+func Replay[T any](source <-chan T, bufferSize int, options ...Option) *Replayable[T] {
+	if source == nil {
+		return nil
+	}
+	history := newUnboundedRing[T]()
+	if bufferSize > 0 {
+		history = newRing[T](bufferSize)
+	}
+	return &Replayable[T]{
+		history:  history,
+		refCount: true,
+		source:   source,
+		defaults: SubscriberConfig{Buffer: 1, Overflow: OverflowBlock},
+		memory:   configure(options).memoryAccount(),
+	}
+}
+
+func (r *Replayable[T]) pump(source <-chan T) {
+	for s := range source {
+		if !r.memory.hold(s) {
+			Stop(source)
+			break
+		}
+		r.mu.Lock()
+		if evicted, full := r.history.push(s); full {
+			r.memory.release(evicted)
+		}
+		subscribers := slices.Clone(r.subscribers)
+		r.mu.Unlock()
+		// Offer outside the lock, so that a slow subscriber does not hold up
+		// Subscribe or the stopping of any other subscriber
+		var disconnected []*subscriber[T]
+		for _, sub := range subscribers {
+			if !sub.offer(s) {
+				disconnected = append(disconnected, sub)
+			}
+		}
+		r.mu.Lock()
+		if len(disconnected) > 0 {
+			r.subscribers = slices.DeleteFunc(r.subscribers, func(sub *subscriber[T]) bool {
+				return slices.Contains(disconnected, sub)
+			})
+		}
+		abandoned := (r.fixed || r.refCount) && len(r.subscribers) == 0
+		r.mu.Unlock()
+		if abandoned {
+			Stop(source)
+		}
+	}
+	r.mu.Lock()
+	r.done = true
+	for _, sub := range r.subscribers {
+		sub.finish()
+	}
+	r.subscribers = nil
+	r.mu.Unlock()
+}
+
+// Returns a new channel that receives the retained recent values
+// and then each value received from the source until it closes.
+// The subscriber's buffer is unbounded, except for a Replayable made by Replay.
+func (r *Replayable[T]) Subscribe() <-chan T {
+	return r.SubscribeWith(r.defaults)
+}
+
+// Like Subscribe, but with the given buffer size and overflow policy
+// for this subscriber only
+func (r *Replayable[T]) SubscribeWith(config SubscriberConfig) <-chan T {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var sub *subscriber[T]
+	var released func()
+	if r.refCount {
+		released = func() { r.release(sub) }
+	}
+	sub = newSubscriber(r.history.values(), config, released)
+	if r.done {
+		sub.finish()
+		return sub.output
+	}
+	r.subscribers = append(r.subscribers, sub)
+	if r.refCount && !r.started {
+		r.started = true
+		go r.pump(r.source)
+	}
+	return sub.output
+}
+
+// Forgets a subscriber that was stopped from downstream,
+// and stops the source if it was the last one
+func (r *Replayable[T]) release(sub *subscriber[T]) {
+	r.mu.Lock()
+	r.subscribers = slices.DeleteFunc(r.subscribers, func(s *subscriber[T]) bool { return s == sub })
+	abandoned := !r.done && len(r.subscribers) == 0
+	r.mu.Unlock()
+	if abandoned {
+		Stop(r.source)
+	}
+}
+
+// Returns a function that, the first time it is called, calls the given factory
+// and starts receiving every value from the channel it returns, keeping them all.
+// Each call, the first included, returns a new channel that receives all of those values,
+// from the cache once they have arrived, so the source runs only once however often,
+// and however late, the result is consumed.
+// The source is received from in full even if every consumer stops early,
+// so it must be finite and modest in size.
+func Memoize[T any](factory func() <-chan T) func() <-chan T {
+	var once sync.Once
+	var r *Replayable[T]
+	return func() <-chan T {
+		once.Do(func() {
+			r = &Replayable[T]{history: newUnboundedRing[T]()}
+			go r.pump(factory())
+		})
+		return r.Subscribe()
+	}
+}
@@ -0,0 +1,91 @@
+package gl
+
+import "context"
+
+// The kind of event a Notification describes
+type NotificationKind int
+
+const (
+	NotifyNext NotificationKind = iota
+	NotifyError
+	NotifyComplete
+)
+
+func (k NotificationKind) String() string {
+	switch k {
+	case NotifyNext:
+		return "next"
+	case NotifyError:
+		return "error"
+	case NotifyComplete:
+		return "complete"
+	}
+	return "unknown"
+}
+
+// One event in the life of a stream, as an explicit value:
+// a value sent (Value is set), an error (Err is set), or the stream's completion
+type Notification[T any] struct {
+	Kind  NotificationKind
+	Value T
+	Err   error
+}
+
+// Sends a Next notification on a new channel for each value received on a channel,
+// and a Complete notification once it closes,
+// so that the whole life of the stream can be logged, persisted, or replayed
+func Materialize[T any](source <-chan T) <-chan Notification[T] {
+	return MaterializeResults(Try(source))
+}
+
+// Like Materialize, but for an error-aware stream:
+// each failed Result becomes an Error notification
+func MaterializeResults[T any](source <-chan Result[T]) <-chan Notification[T] {
+	if source == nil {
+		return nil
+	}
+	output, ctx, done := newStage[Notification[T]](context.Background(), source)
+	go func() {
+		defer done()
+		for s := range source {
+			n := Notification[T]{Kind: NotifyNext, Value: s.Value}
+			if s.Err != nil {
+				n = Notification[T]{Kind: NotifyError, Err: s.Err}
+			}
+			if !send(ctx, output, n) {
+				return
+			}
+		}
+		send(ctx, output, Notification[T]{Kind: NotifyComplete})
+	}()
+	return output
+}
+
+// The inverse of MaterializeResults: sends a successful Result for each Next notification
+// and a failed Result for each Error notification received on a channel.
+// The new channel closes at the first Complete notification,
+// and the source is stopped; it also closes if the source closes without one.
+func Dematerialize[T any](source <-chan Notification[T]) <-chan Result[T] {
+	if source == nil {
+		return nil
+	}
+	output, ctx, done := newStage[Result[T]](context.Background(), source)
+	go func() {
+		defer done()
+		for s := range source {
+			var r Result[T]
+			switch s.Kind {
+			case NotifyNext:
+				r = Ok(s.Value)
+			case NotifyError:
+				r = Fail[T](s.Err)
+			default:
+				return
+			}
+			if !send(ctx, output, r) {
+				return
+			}
+		}
+	}()
+	return output
+}
@@ -0,0 +1,45 @@
+package gl
+
+// Functional options for tuning individual stages, as in
+// gl.Map(source, mapper, gl.WithBuffer(128))
+
+// An Option tunes how an operator builds its stage
+type Option func(*stageConfig)
+
+// The settings an operator's options produce
+type stageConfig struct {
+	buffer       int
+	recover      *ErrorHandle
+	autoParallel bool
+	budget       *MemoryBudget
+	sizeOf       func(any) int64
+}
+
+// Gives the operator's output channel room for n = size values,
+// so that it can run ahead of its consumer instead of handing over
+// one value at a time. The default is an unbuffered channel.
+func WithBuffer(size int) Option {
+	return func(c *stageConfig) {
+		c.buffer = size
+	}
+}
+
+// Makes the operator recover if one of the functions given to it panics:
+// instead of crashing the program, it records the panic as a *PanicError
+// in the given handle, closes its output channel, and stops its source.
+// Every operator that takes options honors it, including for the functions
+// it runs on goroutines of its own.
+func WithRecover(handle *ErrorHandle) Option {
+	return func(c *stageConfig) {
+		c.recover = handle
+	}
+}
+
+// Applies the given options, in order, to the default settings
+func configure(options []Option) stageConfig {
+	var c stageConfig
+	for _, option := range options {
+		option(&c)
+	}
+	return c
+}
@@ -0,0 +1,247 @@
+package gl
+
+import (
+	"container/heap"
+	"context"
+	"time"
+)
+
+// Reorders slightly out-of-order values received on a channel
+// according to their sequence numbers, and sends them in order on a new channel.
+// The sequence number of the first value received is taken as the start of the sequence.
+// Up to n = window values are held while waiting for a missing sequence number;
+// once the window is full (n values are held) the gap is given up on
+// and emission resumes from the lowest held sequence number.
+// Values whose sequence number has already been passed (late arrivals or duplicates) are dropped.
+// A window of zero or less holds values for as long as a gap lasts, without limit,
+// so a sequence number that never arrives makes every later value be held until the source closes;
+// use ResequenceWithin to give up on gaps after a timeout, or WithMemoryBudget to bound what is held.
+func Resequence[T any](source <-chan T, seq func(T) uint64, window int, options ...Option) <-chan T {
+	return ResequenceWithin(source, seq, window, 0, options...)
+}
+
+// Like Resequence, but also gives up on a gap if no value could be emitted
+// for the given timeout while values are being held.
+// A timeout of zero or less disables this, as in Resequence.
+// With WithMemoryBudget, the held values are held within the budget.
+func ResequenceWithin[T any](source <-chan T, seq func(T) uint64, window int, timeout time.Duration, options ...Option) <-chan T {
+	if source == nil {
+		return nil
+	}
+	config := configure(options)
+	output, ctx, done := newConfiguredStage[T](context.Background(), config, source)
+	go func() {
+		defer done()
+		memory := config.memoryAccount()
+		defer memory.releaseAll()
+		held := make(map[uint64]T)
+		var next uint64
+		started := false
+		// One timer, armed whenever values are being held
+		timer := time.NewTimer(timeout)
+		timer.Stop()
+		defer timer.Stop()
+		var expired <-chan time.Time
+		arm := func() {
+			timer.Reset(timeout)
+			expired = timer.C
+		}
+
+		// Sends all held values from next onwards that have no gaps between them.
+		// Reports false if the operator has been stopped.
+		flush := func() bool {
+			for {
+				value, ok := held[next]
+				if !ok {
+					break
+				}
+				delete(held, next)
+				if !send(ctx, output, value) {
+					return false
+				}
+				memory.release(value)
+				next++
+			}
+			timer.Stop()
+			expired = nil
+			if len(held) > 0 && timeout > 0 {
+				arm()
+			}
+			return true
+		}
+		// Gives up on the current gap
+		skip := func() bool {
+			first := true
+			for n := range held {
+				if first || n < next {
+					next = n
+				}
+				first = false
+			}
+			return flush()
+		}
+
+		for {
+			select {
+			case s, more := <-source:
+				if !more {
+					for len(held) > 0 {
+						if !skip() {
+							return
+						}
+					}
+					return
+				}
+				n := seq(s)
+				if !started {
+					next = n
+					started = true
+				}
+				if n < next {
+					continue
+				}
+				if _, dup := held[n]; dup {
+					continue
+				}
+				if !memory.hold(s) {
+					return
+				}
+				held[n] = s
+				if n == next {
+					if !flush() {
+						return
+					}
+				} else if window > 0 && len(held) >= window {
+					if !skip() {
+						return
+					}
+				} else if expired == nil && timeout > 0 {
+					arm()
+				}
+			case <-expired:
+				if !skip() {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// A heap of values ordered by a less function, for use with container/heap
+type priorityHeap[T any] struct {
+	values []T
+	less   func(a, b T) bool
+}
+
+func (h *priorityHeap[T]) Len() int           { return len(h.values) }
+func (h *priorityHeap[T]) Less(i, j int) bool { return h.less(h.values[i], h.values[j]) }
+func (h *priorityHeap[T]) Swap(i, j int)      { h.values[i], h.values[j] = h.values[j], h.values[i] }
+func (h *priorityHeap[T]) Push(x any)         { h.values = append(h.values, x.(T)) }
+func (h *priorityHeap[T]) Pop() any {
+	last := h.values[len(h.values)-1]
+	h.values = h.values[:len(h.values)-1]
+	return last
+}
+
+// Eagerly receives up to n = capacity values from a channel into a buffer
+// and, whenever the consumer is ready, sends the buffered value that comes first
+// according to less, so urgent values jump ahead of ones that arrived earlier.
+// A capacity of zero or less is treated as one, which preserves arrival order.
+// With WithMemoryBudget, the buffered values are held within the budget.
+func PriorityBuffer[T any](source <-chan T, less func(a, b T) bool, capacity int, options ...Option) <-chan T {
+	if source == nil {
+		return nil
+	}
+	capacity = max(capacity, 1)
+	config := configure(options)
+	output, ctx, done := newConfiguredStage[T](context.Background(), config, source)
+	go func() {
+		defer done()
+		memory := config.memoryAccount()
+		defer memory.releaseAll()
+		buffer := &priorityHeap[T]{less: less}
+		input := source
+		for input != nil || buffer.Len() > 0 {
+			// Only receive while there is room, and only send while there is something to send
+			var receiving <-chan T
+			if buffer.Len() < capacity {
+				receiving = input
+			}
+			var sending chan T
+			var top T
+			if buffer.Len() > 0 {
+				sending = output
+				top = buffer.values[0]
+			}
+			select {
+			case s, more := <-receiving:
+				if !more {
+					input = nil
+					continue
+				}
+				if !memory.hold(s) {
+					return
+				}
+				heap.Push(buffer, s)
+			case sending <- top:
+				heap.Pop(buffer)
+				memory.release(top)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// A value along with its position in a sequence, such as an offset in a log
+type Sequenced[T any] struct {
+	Seq   uint64
+	Value T
+}
+
+// Sends each value received on a channel on a new channel,
+// numbered in the order received, starting from zero
+func Sequence[T any](source <-chan T) <-chan Sequenced[T] {
+	var next uint64
+	return Map(source, func(s T) Sequenced[T] {
+		next++
+		return Sequenced[T]{Seq: next - 1, Value: s}
+	})
+}
+
+// Listens on a channel of sequenced values until it is closed and commits each value,
+// in strict sequence order, for writing to systems that require monotonic offsets.
+// The sequence number of the first value received is taken as the start of the sequence.
+// Values whose sequence number has already been passed (late arrivals or duplicates)
+// are not committed. If a value skips ahead, onGap, if not nil, is called
+// with each sequence number skipped, in order, before the value is committed.
+// Values that may arrive out of order should be put back in order first with Resequence.
+// If commit returns an error, the channel is stopped and the error returned.
+func CommitInOrder[T any](source <-chan Sequenced[T], commit func(T) error, onGap func(missing uint64)) error {
+	var next uint64
+	started := false
+	for s := range source {
+		if !started {
+			next = s.Seq
+			started = true
+		}
+		if s.Seq < next {
+			continue
+		}
+		for ; next < s.Seq; next++ {
+			if onGap != nil {
+				onGap(next)
+			}
+		}
+		if err := commit(s.Value); err != nil {
+			Stop(source)
+			return err
+		}
+		next++
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package gl
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// Receives the values ready on a channel within a short wait
+func receiveReady[T any](source <-chan T) []T {
+	var values []T
+	for {
+		select {
+		case s, ok := <-source:
+			if !ok {
+				return values
+			}
+			values = append(values, s)
+		case <-time.After(20 * time.Millisecond):
+			return values
+		}
+	}
+}
+
+func TestResequenceSkipsGapWhenWindowIsFull(t *testing.T) {
+	source := make(chan int)
+	output := Resequence(source, func(n int) uint64 { return uint64(n) }, 3)
+	steps := []struct {
+		send int
+		want []int
+	}{
+		{0, []int{0}},
+		{2, nil},            // 1 missing; one value held
+		{3, nil},            // two values held
+		{4, []int{2, 3, 4}}, // three values held: the window is full, so 1 is given up on
+		{1, nil},            // already passed, so dropped
+		{6, nil},
+		{5, []int{5, 6}},
+	}
+	for _, step := range steps {
+		source <- step.send
+		if got := receiveReady(output); !slices.Equal(got, step.want) {
+			t.Fatalf("after sending %d: got %v, want %v", step.send, got, step.want)
+		}
+	}
+	close(source)
+	if got := receiveReady(output); len(got) != 0 {
+		t.Fatalf("after closing: got %v, want nothing", got)
+	}
+}
+
+func TestResequenceWindowOfOne(t *testing.T) {
+	got := ToSlice(Resequence(From([]int{1, 3, 2, 4}), func(n int) uint64 { return uint64(n) }, 1))
+	if want := []int{1, 3, 4}; !slices.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,338 @@
+package gl
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Parallel operators
+
+// The per-worker queues of a work-stealing stage.
+// Values are dealt out round-robin; a worker whose own queue is empty
+// steals from the back of another worker's queue.
+type stealQueues[T any] struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queues [][]T
+	queued int
+	closed bool
+}
+
+func newStealQueues[T any](workers int) *stealQueues[T] {
+	q := &stealQueues[T]{queues: make([][]T, workers)}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// Adds a value to the given worker's queue, waiting while limit values are already queued
+func (q *stealQueues[T]) put(worker int, value T, limit int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for q.queued >= limit && !q.closed {
+		q.cond.Wait()
+	}
+	q.queues[worker] = append(q.queues[worker], value)
+	q.queued++
+	q.cond.Broadcast()
+}
+
+// Takes the next value for the given worker: from the front of its own queue if possible,
+// otherwise from the back of the fullest other queue.
+// Reports false once the queues are closed and empty.
+func (q *stealQueues[T]) take(worker int) (T, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for {
+		if own := q.queues[worker]; len(own) > 0 {
+			value := own[0]
+			q.queues[worker] = own[1:]
+			q.queued--
+			q.cond.Broadcast()
+			return value, true
+		}
+		victim := -1
+		for i, other := range q.queues {
+			if len(other) > 0 && (victim < 0 || len(other) > len(q.queues[victim])) {
+				victim = i
+			}
+		}
+		if victim >= 0 {
+			other := q.queues[victim]
+			value := other[len(other)-1]
+			q.queues[victim] = other[:len(other)-1]
+			q.queued--
+			q.cond.Broadcast()
+			return value, true
+		}
+		if q.closed {
+			var zero T
+			return zero, false
+		}
+		q.cond.Wait()
+	}
+}
+
+// Marks the queues closed; workers finish what is queued and then stop
+func (q *stealQueues[T]) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.cond.Broadcast()
+}
+
+// Makes a parallel operator size itself from runtime.GOMAXPROCS instead of
+// the worker (or shard) count given to it: it starts one worker per GOMAXPROCS,
+// and the worker pools of ParallelMap and its kin start or retire workers
+// when GOMAXPROCS changes while they run (as it may when a container's CPU quota changes).
+// A positive count given to the operator still caps the number of workers.
+// Only GOMAXPROCS is followed, not the load on the machine,
+// for which the runtime offers no portable measure.
+func WithAutoParallel() Option {
+	return func(c *stageConfig) {
+		c.autoParallel = true
+	}
+}
+
+// How often automatically sized parallel operators check GOMAXPROCS
+const autoParallelInterval = 100 * time.Millisecond
+
+// Returns the most workers a parallel operator given the worker count and options may run:
+// the count itself (at least one), or with WithAutoParallel,
+// enough for every CPU unless the count is positive
+func workerLimit(workers int, config stageConfig) int {
+	if config.autoParallel && workers <= 0 {
+		return max(runtime.NumCPU(), runtime.GOMAXPROCS(0))
+	}
+	return max(workers, 1)
+}
+
+// Manages the workers of a parallel operator.
+// With a fixed worker count, that many workers run until they finish.
+// With WithAutoParallel, the count follows GOMAXPROCS: new workers are started
+// when it grows, and workers whose index is no longer below it retire.
+type workerPool struct {
+	mu       sync.Mutex
+	alive    map[int]bool
+	started  bool
+	limit    int
+	target   atomic.Int64
+	finished func()
+	recover  *ErrorHandle
+	abort    func()
+}
+
+// Starts the pool's workers, never more than limit at once.
+// Each runs work with its index until work returns,
+// which it should do when active reports false.
+// Calls finished once the last worker has returned.
+// If the operator was given WithRecover, a worker that panics records the panic
+// and calls abort, which should stop the operator.
+func startWorkerPool(ctx context.Context, config stageConfig, limit int, work func(index int, active func() bool), finished func(), abort func()) *workerPool {
+	p := &workerPool{alive: make(map[int]bool), limit: limit, finished: finished, recover: config.recover, abort: abort}
+	workers := limit
+	if config.autoParallel {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	p.grow(workers, work)
+	if config.autoParallel {
+		go func() {
+			ticker := time.NewTicker(autoParallelInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					p.grow(runtime.GOMAXPROCS(0), work)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	return p
+}
+
+// The number of workers that should currently be running
+func (p *workerPool) size() int {
+	return int(p.target.Load())
+}
+
+// Sets the target number of workers to n and starts any that are missing,
+// unless every worker has already returned
+func (p *workerPool) grow(n int, work func(index int, active func() bool)) {
+	n = max(min(n, p.limit), 1)
+	p.target.Store(int64(n))
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.started && len(p.alive) == 0 {
+		return
+	}
+	p.started = true
+	for index := range n {
+		if p.alive[index] {
+			continue
+		}
+		p.alive[index] = true
+		go func() {
+			defer func() {
+				if p.recover != nil {
+					if r := recover(); r != nil {
+						p.recover.record(newPanicError(r))
+						p.abort()
+					}
+				}
+				p.mu.Lock()
+				delete(p.alive, index)
+				last := len(p.alive) == 0
+				p.mu.Unlock()
+				if last {
+					p.finished()
+				}
+			}()
+			work(index, func() bool { return index < p.size() })
+		}()
+	}
+}
+
+// A value waiting to be mapped by a parallel worker,
+// and where to put the result
+type parallelJob[T1 any, T2 any] struct {
+	value  T1
+	result chan T2
+}
+
+// Applies the given mapper to each value from a channel on the given number of
+// worker goroutines, and sends the results on a new channel in the order
+// the values were received.
+// At most two more values than there are workers are in flight at once
+// (one queued for each worker, one whose result is being sent, and one waiting to be queued),
+// so a slow value holds up the results behind it without the backlog growing unbounded.
+// Pass WithAutoParallel to follow GOMAXPROCS.
+func ParallelMap[T1 any, T2 any](source <-chan T1, mapper func(T1) T2, workers int, options ...Option) <-chan T2 {
+	if source == nil {
+		return nil
+	}
+	config := configure(options)
+	capacity := workerLimit(workers, config)
+	output, ctx, done := newConfiguredStage[T2](context.Background(), config, source)
+	jobs := NewBuffered[parallelJob[T1, T2]](0)
+	pending := NewBuffered[chan T2](capacity)
+	startWorkerPool(ctx, config, capacity, func(w int, active func() bool) {
+		for active() {
+			job, ok := receive(ctx, jobs)
+			if !ok {
+				return
+			}
+			job.result <- mapper(job.value) // buffered, so never blocks
+		}
+	}, func() {}, func() { Stop[T2](output) })
+	go func() {
+		defer close(jobs)
+		defer close(pending)
+		for {
+			s, ok := receive(ctx, source)
+			if !ok {
+				return
+			}
+			result := NewBuffered[T2](1)
+			if !send(ctx, pending, result) || !send(ctx, jobs, parallelJob[T1, T2]{value: s, result: result}) {
+				return
+			}
+		}
+	}()
+	go func() {
+		defer done()
+		for result := range pending {
+			value, ok := receive(ctx, result)
+			if !ok || !send(ctx, output, value) {
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// Applies the given mapper to each value from a channel on the given number of
+// worker goroutines, and sends each result as soon as its worker finishes (in no particular order),
+// so a slow value never holds up the results of the values behind it.
+// Pass WithAutoParallel to follow GOMAXPROCS.
+func ParallelMapUnordered[T1 any, T2 any](source <-chan T1, mapper func(T1) T2, workers int, options ...Option) <-chan T2 {
+	return parallelUnordered(source, workers, func(s T1) (T2, bool) { return mapper(s), true }, options)
+}
+
+// Applies the given predicate to each value from a channel on the given number of
+// worker goroutines, and sends the values for which it returns true
+// as soon as their worker finishes (in no particular order).
+// Pass WithAutoParallel to follow GOMAXPROCS.
+func ParallelFilterUnordered[T any](source <-chan T, predicate func(T) bool, workers int, options ...Option) <-chan T {
+	return parallelUnordered(source, workers, func(s T) (T, bool) { return s, predicate(s) }, options)
+}
+
+// Runs the given function on each value from a channel on the given number of workers,
+// each receiving directly from the channel, and sends the results it keeps
+func parallelUnordered[T1 any, T2 any](source <-chan T1, workers int, apply func(T1) (T2, bool), options []Option) <-chan T2 {
+	if source == nil {
+		return nil
+	}
+	config := configure(options)
+	output, ctx, done := newConfiguredStage[T2](context.Background(), config, source)
+	startWorkerPool(ctx, config, workerLimit(workers, config), func(w int, active func() bool) {
+		for active() {
+			s, ok := receive(ctx, source)
+			if !ok {
+				return
+			}
+			if result, keep := apply(s); keep && !send(ctx, output, result) {
+				return
+			}
+		}
+	}, done, func() { Stop[T2](output) })
+	return output
+}
+
+// Applies the given mapper to each value from a channel on the given number of
+// worker goroutines, and sends the results as soon as they are ready (in no particular order).
+// Values are dealt out to the workers round-robin, but an idle worker steals
+// queued values from a busy one, so a few very expensive values do not leave
+// the other workers idle.
+// Pass WithAutoParallel to follow GOMAXPROCS.
+func ParallelMapStealing[T1 any, T2 any](source <-chan T1, mapper func(T1) T2, workers int, options ...Option) <-chan T2 {
+	if source == nil {
+		return nil
+	}
+	config := configure(options)
+	capacity := workerLimit(workers, config)
+	output, ctx, done := newConfiguredStage[T2](context.Background(), config, source)
+	queues := newStealQueues[T1](capacity)
+	pool := startWorkerPool(ctx, config, capacity, func(w int, active func() bool) {
+		for active() {
+			s, ok := queues.take(w)
+			if !ok {
+				return
+			}
+			if !send(ctx, output, mapper(s)) {
+				queues.close() // stopped; release the dispatcher
+				return
+			}
+		}
+	}, done, func() {
+		queues.close()
+		Stop[T2](output)
+	})
+	go func() {
+		defer queues.close()
+		next := 0
+		for {
+			s, ok := receive(ctx, source)
+			if !ok {
+				return
+			}
+			size := pool.size()
+			queues.put(next%size, s, 2*size)
+			next = (next + 1) % size
+		}
+	}()
+	return output
+}
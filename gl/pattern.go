@@ -0,0 +1,121 @@
+package gl
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// One step of a Pattern: Times values (one if Times is zero or less)
+// for which Match returns true
+type PatternStep[T any] struct {
+	Match func(T) bool
+	Times int
+}
+
+// An ordered sequence of steps for MatchSequence to detect,
+// such as three failed logins followed by a successful one
+type Pattern[T any] []PatternStep[T]
+
+// A partial match of a pattern
+type patternRun[T any] struct {
+	started time.Time
+	step    int // index of the step being matched
+	count   int // values matched so far for that step
+	matched []T
+}
+
+// The state of MatchSequence: the partial matches in progress,
+// at most one for each point (step and count) of the pattern
+type patternMatcher[T any] struct {
+	pattern Pattern[T]
+	within  time.Duration
+	runs    []*patternRun[T]
+}
+
+// Feeds one value received at the given time to the matcher,
+// returning the values of the occurrence it completes, if any
+func (m *patternMatcher[T]) feed(s T, now time.Time) ([]T, bool) {
+	if m.within > 0 {
+		m.runs = slices.DeleteFunc(m.runs, func(run *patternRun[T]) bool {
+			return now.Sub(run.started) > m.within
+		})
+	}
+	if m.pattern[0].Match(s) {
+		m.runs = append(m.runs, &patternRun[T]{started: now})
+	}
+	var complete *patternRun[T]
+	for _, run := range m.runs {
+		step := m.pattern[run.step]
+		if !step.Match(s) {
+			continue
+		}
+		run.matched = append(run.matched, s)
+		run.count++
+		if run.count >= max(step.Times, 1) {
+			run.step++
+			run.count = 0
+		}
+		if run.step == len(m.pattern) && complete == nil {
+			complete = run
+		}
+	}
+	if complete != nil {
+		m.runs = nil
+		return complete.matched, true
+	}
+	m.collapse()
+	return nil, false
+}
+
+// Keeps one partial match for each point of the pattern, since from here on
+// they would all match the same values: the oldest, or if there is a time limit,
+// the newest, which has the most time left to complete.
+// This bounds the partial matches by the total Times of the pattern's steps.
+func (m *patternMatcher[T]) collapse() {
+	type point struct{ step, count int }
+	kept := make(map[point]*patternRun[T], len(m.runs))
+	m.runs = slices.DeleteFunc(m.runs, func(run *patternRun[T]) bool {
+		at := point{run.step, run.count}
+		other, ok := kept[at]
+		if !ok {
+			kept[at] = run
+			return false
+		}
+		if m.within > 0 && run.started.After(other.started) {
+			*other = *run
+		}
+		return true
+	})
+}
+
+// Detects occurrences of the given pattern among the values received on a channel
+// and sends the values making up each occurrence on a new channel.
+// Values need not be adjacent: a value that does not fit the next step of a partial match
+// is ignored by it. An occurrence must be completed within the given duration
+// of its first value being received (no limit if zero or less).
+// Partial matches that have reached the same point of the pattern are merged,
+// so at most one is kept per value the pattern needs, however long the stream.
+// Once an occurrence is found, every other partial match is dropped,
+// so occurrences do not share values.
+func MatchSequence[T any](source <-chan T, pattern Pattern[T], within time.Duration, options ...Option) <-chan []T {
+	if source == nil {
+		return nil
+	}
+	output, ctx, done := newConfiguredStage[[]T](context.Background(), configure(options), source)
+	go func() {
+		defer done()
+		if len(pattern) == 0 {
+			return
+		}
+		matcher := patternMatcher[T]{pattern: pattern, within: within}
+		for s := range source {
+			if matched, ok := matcher.feed(s, time.Now()); ok {
+				if !send(ctx, output, matched) {
+					return
+				}
+			}
+		}
+	}()
+	return output
+}
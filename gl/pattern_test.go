@@ -0,0 +1,49 @@
+package gl
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMatchSequenceBoundsPartialMatches(t *testing.T) {
+	isA := func(s string) bool { return s == "a" }
+	isB := func(s string) bool { return s == "b" }
+	pattern := Pattern[string]{{Match: isA, Times: 3}, {Match: isB}}
+	for _, within := range []time.Duration{0, time.Hour} {
+		matcher := patternMatcher[string]{pattern: pattern, within: within}
+		start := time.Now()
+		for i := range 10000 {
+			if _, ok := matcher.feed("a", start.Add(time.Duration(i))); ok {
+				t.Fatalf("within %v: unexpected match after %d values", within, i+1)
+			}
+			if len(matcher.runs) > 3 {
+				t.Fatalf("within %v: %d partial matches after %d values", within, len(matcher.runs), i+1)
+			}
+		}
+		matched, ok := matcher.feed("b", start.Add(time.Minute))
+		if !ok || len(matched) != 4 {
+			t.Fatalf("within %v: got %v, %v; want a match of 4 values", within, matched, ok)
+		}
+	}
+}
+
+func TestMatchSequenceKeepsNewestWithinLimit(t *testing.T) {
+	isA := func(s string) bool { return s == "a" }
+	isB := func(s string) bool { return s == "b" }
+	matcher := patternMatcher[string]{pattern: Pattern[string]{{Match: isA}, {Match: isB}}, within: time.Second}
+	start := time.Now()
+	matcher.feed("a", start)
+	matcher.feed("a", start.Add(900*time.Millisecond))
+	if _, ok := matcher.feed("b", start.Add(1500*time.Millisecond)); !ok {
+		t.Fatal("the second partial match should still complete in time")
+	}
+}
+
+func TestMatchSequence(t *testing.T) {
+	values := From([]int{1, 2, 1, 1, 3, 2})
+	pattern := Pattern[int]{{Match: func(n int) bool { return n == 1 }, Times: 2}, {Match: func(n int) bool { return n == 2 }}}
+	got := ToSlice(MatchSequence(values, pattern, 0))
+	if len(got) != 1 || len(got[0]) != 3 || got[0][0] != 1 || got[0][2] != 2 {
+		t.Fatalf("got %v", got)
+	}
+}
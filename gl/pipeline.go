@@ -0,0 +1,327 @@
+package gl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A source template registered with RegisterSource
+type sourceTemplate struct {
+	out   reflect.Type
+	build func(Params) (func(context.Context) <-chan any, error)
+}
+
+// A sink template registered with RegisterSink
+type sinkTemplate struct {
+	in    reflect.Type
+	build func(Params) (func(<-chan any) error, error)
+}
+
+// Registers a source template under the given name, for use by LoadPipeline.
+// build is called with the parameters from the config to make the source,
+// which is started with the context of each run and must stop when it is cancelled,
+// as the Ctx variants of the generators in this package do.
+// Returns an error if the name is already taken by another source.
+func RegisterSource[T any](r *StageRegistry, name string, build func(Params) (func(context.Context) <-chan T, error)) error {
+	t := &sourceTemplate{
+		out: reflect.TypeFor[T](),
+		build: func(params Params) (func(context.Context) <-chan any, error) {
+			source, err := build(params)
+			if err != nil {
+				return nil, err
+			}
+			return func(ctx context.Context) <-chan any {
+				return Map(source(ctx), func(s T) any { return s })
+			}, nil
+		},
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, taken := r.sources[name]; taken {
+		return fmt.Errorf("gl: source %q is already registered", name)
+	}
+	r.sources[name] = t
+	return nil
+}
+
+// Registers a sink template under the given name, for use by LoadPipeline.
+// build is called with the parameters from the config to make the sink,
+// which receives everything the pipeline sends and returns once the channel closes,
+// or earlier with an error.
+// Returns an error if the name is already taken by another sink.
+func RegisterSink[T any](r *StageRegistry, name string, build func(Params) (func(<-chan T) error, error)) error {
+	t := &sinkTemplate{
+		in: reflect.TypeFor[T](),
+		build: func(params Params) (func(<-chan any) error, error) {
+			sink, err := build(params)
+			if err != nil {
+				return nil, err
+			}
+			return func(source <-chan any) error {
+				return sink(Map(source, func(s any) T { return s.(T) }))
+			}, nil
+		},
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, taken := r.sinks[name]; taken {
+		return fmt.Errorf("gl: sink %q is already registered", name)
+	}
+	r.sinks[name] = t
+	return nil
+}
+
+// The declaration of a pipeline read by LoadPipeline, for example:
+//
+//	{
+//		"name": "errors",
+//		"source": {"name": "tail", "params": {"path": "/var/log/app.log"}},
+//		"stages": [
+//			{"name": "json-decode"},
+//			{"name": "level-at-least", "params": {"level": "error"}}
+//		],
+//		"sink": {"name": "stdout"},
+//		"stall_after": "5m"
+//	}
+type PipelineConfig struct {
+	Name   string      `json:"name"`
+	Source StageSpec   `json:"source"`
+	Stages []StageSpec `json:"stages"`
+	Sink   StageSpec   `json:"sink"`
+	// How long a run may go without any stage sending a value
+	// before it is reported as stalled, such as "30s"; empty for never
+	StallAfter string `json:"stall_after,omitempty"`
+}
+
+// A pipeline assembled from registered templates:
+// a source, the stages its values go through, and a sink.
+// Its health can be read with Health, or served as JSON over HTTP, as it runs.
+type Pipeline struct {
+	Name string
+	// How long a run may go without any stage sending a value
+	// before it is reported as stalled; default 0, never
+	StallAfter time.Duration
+	names      []string // of the source and each stage
+	config     PipelineConfig
+	registry   *StageRegistry
+
+	mu     sync.Mutex
+	state  PipelineState
+	since  time.Time
+	err    error
+	runs   int
+	probes []*stageProbe // of the latest run
+}
+
+// Reads a pipeline declared as JSON (see PipelineConfig) and assembles it
+// from the templates in the given registry, checking that the source, stages and sink
+// fit together, so that pipelines can be changed in a config file without recompiling.
+// Configs written in another format such as YAML can be decoded into
+// a PipelineConfig and given to NewPipeline instead.
+func LoadPipeline(config []byte, registry *StageRegistry) (*Pipeline, error) {
+	var c PipelineConfig
+	if err := json.Unmarshal(config, &c); err != nil {
+		return nil, fmt.Errorf("gl: reading pipeline config: %w", err)
+	}
+	return NewPipeline(c, registry)
+}
+
+// Assembles the pipeline declared by the given config
+// from the templates in the given registry
+func NewPipeline(config PipelineConfig, registry *StageRegistry) (*Pipeline, error) {
+	// Build once up front, only to check the config; each run builds afresh
+	if _, err := assemblePipeline(config, registry); err != nil {
+		return nil, err
+	}
+	p := &Pipeline{Name: config.Name, config: config, registry: registry, state: PipelineIdle, since: time.Now()}
+	if config.StallAfter != "" {
+		var err error
+		if p.StallAfter, err = time.ParseDuration(config.StallAfter); err != nil {
+			return nil, fmt.Errorf("gl: reading pipeline config: stall_after: %w", err)
+		}
+	}
+	p.names = append(p.names, config.Source.Name)
+	for _, spec := range config.Stages {
+		p.names = append(p.names, spec.Name)
+	}
+	return p, nil
+}
+
+// The source, stages and sink of one run of a pipeline
+type pipelineParts struct {
+	source func(context.Context) <-chan any
+	stages []Stage[any, any]
+	sink   func(<-chan any) error
+}
+
+// Builds the source, stages and sink declared by the given config
+// from the templates in the given registry, checking that they fit together
+func assemblePipeline(config PipelineConfig, registry *StageRegistry) (pipelineParts, error) {
+	var parts pipelineParts
+	registry.mu.RLock()
+	source, sourceOk := registry.sources[config.Source.Name]
+	sink, sinkOk := registry.sinks[config.Sink.Name]
+	registry.mu.RUnlock()
+	if !sourceOk {
+		return parts, fmt.Errorf("%w: no source %q", ErrUnknownStage, config.Source.Name)
+	}
+	if !sinkOk {
+		return parts, fmt.Errorf("%w: no sink %q", ErrUnknownStage, config.Sink.Name)
+	}
+	stages, in, out, err := registry.build(config.Stages)
+	if err != nil {
+		return parts, err
+	}
+	if len(config.Stages) == 0 {
+		in, out = source.out, source.out
+	}
+	if in != source.out {
+		return parts, fmt.Errorf("%w: source %q sends %v but stage %q receives %v", ErrStageType, config.Source.Name, source.out, config.Stages[0].Name, in)
+	}
+	if out != sink.in {
+		return parts, fmt.Errorf("%w: pipeline sends %v but sink %q receives %v", ErrStageType, out, config.Sink.Name, sink.in)
+	}
+	parts.stages = stages
+	if parts.source, err = source.build(config.Source.Params); err != nil {
+		return parts, fmt.Errorf("building source %q: %w", config.Source.Name, err)
+	}
+	if parts.sink, err = sink.build(config.Sink.Params); err != nil {
+		return parts, fmt.Errorf("building sink %q: %w", config.Sink.Name, err)
+	}
+	return parts, nil
+}
+
+// Runs the pipeline until its source is exhausted, its sink fails, or ctx is cancelled,
+// and returns the sink's error, or the context's error if it was cancelled.
+// A pipeline may be run any number of times, even concurrently:
+// each run builds its source, stages and sink afresh from the registry,
+// so no state is carried from one run into another. Its health describes the latest run.
+func (p *Pipeline) Run(ctx context.Context) error {
+	parts, err := assemblePipeline(p.config, p.registry)
+	if err != nil {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.state, p.since, p.err = PipelineFailed, time.Now(), err
+		p.runs++
+		return err
+	}
+	probes := make([]*stageProbe, len(p.names))
+	for i, name := range p.names {
+		probes[i] = &stageProbe{name: name}
+	}
+	output := probes[0].watch(parts.source(ctx))
+	for i, stage := range parts.stages {
+		output = probes[i+1].watch(stage(output))
+	}
+	p.mu.Lock()
+	p.state, p.since, p.err, p.probes = PipelineRunning, time.Now(), nil, probes
+	p.runs++
+	p.mu.Unlock()
+
+	err = parts.sink(output)
+	Stop(output)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch {
+	case err != nil:
+		p.state, p.err = PipelineFailed, err
+	case ctx.Err() != nil:
+		p.state, err = PipelineStopped, ctx.Err()
+	default:
+		p.state = PipelineFinished
+	}
+	p.since = time.Now()
+	return err
+}
+
+// Runs the pipeline on a goroutine of the given group, such as an *errgroup.Group,
+// which is given the error returned by Run
+func (p *Pipeline) Go(ctx context.Context, group Group) {
+	group.Go(func() error {
+		return p.Run(ctx)
+	})
+}
+
+// Watches the values sent by one stage of a pipeline run
+type stageProbe struct {
+	name  string
+	sent  atomic.Int64
+	last  atomic.Int64 // in Unix nanoseconds; zero before the first value
+	queue func() int
+}
+
+// Returns a channel that forwards the stage's output, counting what it sends
+func (s *stageProbe) watch(output <-chan any) <-chan any {
+	s.queue = func() int { return len(output) }
+	return Tap(output, func(any) {
+		s.sent.Add(1)
+		s.last.Store(time.Now().UnixNano())
+	})
+}
+
+// The health of one stage of a pipeline (the first being its source)
+type StageHealth struct {
+	Name       string
+	Sent       int64     // values sent so far in the latest run
+	LastValue  time.Time // when the latest of them was sent; zero if none
+	QueueDepth int       // values waiting in the stage's output buffer
+}
+
+// Returns the health of the pipeline: its state (idle before its first run,
+// running, stalled, finished, failed, or stopped if its context was cancelled)
+// and that of each of its stages
+func (p *Pipeline) Health() PipelineHealth {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	h := PipelineHealth{Name: p.Name, State: p.state, Since: p.since, Restarts: max(p.runs-1, 0), LastError: p.err}
+	progress := p.since
+	for _, probe := range p.probes {
+		s := StageHealth{Name: probe.name, Sent: probe.sent.Load(), QueueDepth: probe.queue()}
+		if last := probe.last.Load(); last != 0 {
+			s.LastValue = time.Unix(0, last)
+			progress = later(progress, s.LastValue)
+		}
+		h.Stages = append(h.Stages, s)
+	}
+	if h.State == PipelineRunning && p.StallAfter > 0 && time.Since(progress) > p.StallAfter {
+		h.State, h.Since = PipelineStalled, progress
+	}
+	return h
+}
+
+// Serves the pipeline's health as JSON, with status 200 if it is healthy
+// and 503 otherwise, for readiness and liveness probes and dashboards
+func (p *Pipeline) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h := p.Health()
+	serveHealth(w, h, h.Healthy())
+}
+
+func later(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
+// Writes the given health report as JSON with a status code saying whether it is healthy
+func serveHealth(w http.ResponseWriter, report any, healthy bool) {
+	body, err := json.Marshal(report)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if healthy {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	w.Write(body)
+}
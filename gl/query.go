@@ -0,0 +1,147 @@
+package gl
+
+// A Query wraps a channel so that operators can be chained left to right,
+// as in gl.QueryFrom(ints).Filter(isEven).Map(square).Take(3).ToSlice().
+// Go methods cannot introduce type parameters, so operators that change
+// the element type are free functions taking a Query, such as Select.
+type Query[T any] struct {
+	source <-chan T
+}
+
+// Wraps a channel in a Query
+func NewQuery[T any](source <-chan T) Query[T] {
+	return Query[T]{source: source}
+}
+
+// Wraps the elements of a slice in a Query
+func QueryFrom[T any](source []T) Query[T] {
+	return NewQuery(From(source))
+}
+
+// Returns the underlying channel
+func (q Query[T]) Chan() <-chan T {
+	return q.source
+}
+
+// Applies a function that changes the element type to each element of a Query
+func Select[T1 any, T2 any](q Query[T1], mapper func(T1) T2, options ...Option) Query[T2] {
+	return NewQuery(Map(q.source, mapper, options...))
+}
+
+// Applies a function to each element of the query; see Map
+func (q Query[T]) Map(mapper func(T) T, options ...Option) Query[T] {
+	return NewQuery(Map(q.source, mapper, options...))
+}
+
+// Keeps the elements of the query that satisfy a predicate; see Filter
+func (q Query[T]) Filter(predicate func(T) bool, options ...Option) Query[T] {
+	return NewQuery(Filter(q.source, predicate, options...))
+}
+
+// Keeps the first count elements of the query; see Take
+func (q Query[T]) Take(count int, options ...Option) Query[T] {
+	return NewQuery(Take(q.source, count, options...))
+}
+
+// Skips the first count elements of the query; see Skip
+func (q Query[T]) Skip(count int, options ...Option) Query[T] {
+	return NewQuery(Skip(q.source, count, options...))
+}
+
+// Keeps elements of the query while a predicate holds; see TakeWhile
+func (q Query[T]) TakeWhile(predicate func(T) bool, options ...Option) Query[T] {
+	return NewQuery(TakeWhile(q.source, predicate, options...))
+}
+
+// Skips elements of the query while a predicate holds; see SkipWhile
+func (q Query[T]) SkipWhile(predicate func(T) bool, options ...Option) Query[T] {
+	return NewQuery(SkipWhile(q.source, predicate, options...))
+}
+
+// Keeps the last count elements of the query; see TakeLast
+func (q Query[T]) TakeLast(count int, options ...Option) Query[T] {
+	return NewQuery(TakeLast(q.source, count, options...))
+}
+
+// Skips the last count elements of the query; see SkipLast
+func (q Query[T]) SkipLast(count int, options ...Option) Query[T] {
+	return NewQuery(SkipLast(q.source, count, options...))
+}
+
+// Reverses the order of the elements of the query; see Reverse
+func (q Query[T]) Reverse(options ...Option) Query[T] {
+	return NewQuery(Reverse(q.source, options...))
+}
+
+// Adds the given values after the elements of the query; see Append
+func (q Query[T]) Append(values ...T) Query[T] {
+	return NewQuery(Append(q.source, values...))
+}
+
+// Adds the given values before the elements of the query; see Prepend
+func (q Query[T]) Prepend(values ...T) Query[T] {
+	return NewQuery(Prepend(q.source, values...))
+}
+
+// Sends the given value if the query has no elements; see DefaultIfEmpty
+func (q Query[T]) DefaultIfEmpty(defaultValue T, options ...Option) Query[T] {
+	return NewQuery(DefaultIfEmpty(q.source, defaultValue, options...))
+}
+
+// Passes each element of the query to an observer as it goes by; see Tap
+func (q Query[T]) Tap(observe func(T), options ...Option) Query[T] {
+	return NewQuery(Tap(q.source, observe, options...))
+}
+
+// Aggregation methods
+
+// Collects the elements of the query into a slice; see ToSlice
+func (q Query[T]) ToSlice() []T {
+	return ToSlice(q.source)
+}
+
+// Returns the first element of the query; see First
+func (q Query[T]) First() T {
+	return First(q.source)
+}
+
+// Returns the first element of the query and whether there was one; see FirstOk
+func (q Query[T]) FirstOk() (T, bool) {
+	return FirstOk(q.source)
+}
+
+// Returns the last element of the query; see Last
+func (q Query[T]) Last() T {
+	return Last(q.source)
+}
+
+// Returns the last element of the query and whether there was one; see LastOk
+func (q Query[T]) LastOk() (T, bool) {
+	return LastOk(q.source)
+}
+
+// Returns the element of the query at the given index and whether there was one; see ElementAt
+func (q Query[T]) ElementAt(index int) (T, bool) {
+	return ElementAt(q.source, index)
+}
+
+// Returns the number of elements in the query; see Count
+func (q Query[T]) Count() int {
+	return Count(q.source)
+}
+
+// Returns the number of elements in the query that satisfy a predicate; see CountIf
+func (q Query[T]) CountIf(predicate func(T) bool) int {
+	return CountIf(q.source, predicate)
+}
+
+// Stops the query; see Stop
+func (q Query[T]) Stop() {
+	Stop(q.source)
+}
+
+// Starts a goroutine in the given group that passes each element of the query
+// to the given sink; see GoEach
+func (q Query[T]) Go(group Group, sink func(T) error) {
+	GoEach(group, q.source, sink)
+}
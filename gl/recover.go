@@ -0,0 +1,79 @@
+package gl
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// A panic recovered from a function given to an operator
+type PanicError struct {
+	Value any    // the value passed to panic
+	Stack []byte // the stack of the goroutine that panicked
+}
+
+func newPanicError(value any) *PanicError {
+	return &PanicError{Value: value, Stack: debug.Stack()}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("gl: recovered panic: %v", e.Value)
+}
+
+// Unwraps the panic value if it was itself an error
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Collects the errors of a pipeline whose operators were given WithRecover,
+// so they can be checked once the pipeline has finished.
+// The zero value is ready to use.
+type ErrorHandle struct {
+	mu  sync.Mutex
+	err error
+}
+
+// Keeps the first error recorded
+func (h *ErrorHandle) record(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.err == nil {
+		h.err = err
+	}
+}
+
+// Returns the first error recorded, or nil if there was none
+func (h *ErrorHandle) Err() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.err
+}
+
+// Forwards each value received on a channel as a successful Result.
+// Once the channel closes, if the given handle holds an error
+// (such as a panic recovered upstream), it is sent as a failed Result,
+// so that the failure travels down the pipeline with the values.
+// A nil handle means there is nothing to check.
+func Catch[T any](source <-chan T, handle *ErrorHandle) <-chan Result[T] {
+	if source == nil {
+		return nil
+	}
+	output, ctx, done := newStage[Result[T]](context.Background(), source)
+	go func() {
+		defer done()
+		for s := range source {
+			if !send(ctx, output, Ok(s)) {
+				return
+			}
+		}
+		if handle == nil {
+			return
+		}
+		if err := handle.Err(); err != nil {
+			send(ctx, output, Fail[T](err))
+		}
+	}()
+	return output
+}
@@ -0,0 +1,156 @@
+package gl
+
+import (
+	"context"
+	"maps"
+	"slices"
+)
+
+// A Result carries either a value or the error that prevented one
+// through a pipeline of fallible operators
+type Result[T any] struct {
+	Value T
+	Err   error
+}
+
+// Returns a successful Result holding the given value
+func Ok[T any](value T) Result[T] {
+	return Result[T]{Value: value}
+}
+
+// Returns a failed Result holding the given error
+func Fail[T any](err error) Result[T] {
+	return Result[T]{Err: err}
+}
+
+// Wraps each element received on a channel in a successful Result
+// and sends it on a new channel, so that it can be fed to the Try operators
+func Try[T any](source <-chan T) <-chan Result[T] {
+	return Map(source, Ok[T])
+}
+
+// Applies the given fallible mapper to the value of each successful Result received on a channel
+// and sends the outcome on a new channel.
+// Failed Results are passed along without calling the mapper.
+func TryMap[T1 any, T2 any](source <-chan Result[T1], mapper func(T1) (T2, error), options ...Option) <-chan Result[T2] {
+	return Map(source, func(r Result[T1]) Result[T2] {
+		if r.Err != nil {
+			return Fail[T2](r.Err)
+		}
+		value, err := mapper(r.Value)
+		return Result[T2]{Value: value, Err: err}
+	}, options...)
+}
+
+// Applies the given fallible predicate to the value of each successful Result received on a channel
+// and sends those for which it returns true on a new channel.
+// If the predicate fails, a failed Result with its error is sent instead.
+// Failed Results are passed along without calling the predicate.
+func TryFilter[T any](source <-chan Result[T], predicate func(T) (bool, error), options ...Option) <-chan Result[T] {
+	if source == nil {
+		return nil
+	}
+	output, ctx, done := newConfiguredStage[Result[T]](context.Background(), configure(options), source)
+	go func() {
+		defer done()
+		for r := range source {
+			if r.Err == nil {
+				keep, err := predicate(r.Value)
+				if err != nil {
+					r = Fail[T](err)
+				} else if !keep {
+					continue
+				}
+			}
+			if !send(ctx, output, r) {
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// Starting from the given seed, combines the value of each Result received on a channel
+// into an accumulator with the given fallible function, and returns the final accumulator.
+// Stops at the first failed Result or failed call to accumulate,
+// returning the accumulator so far along with that error.
+func TryReduce[T any, A any](source <-chan Result[T], seed A, accumulate func(A, T) (A, error)) (A, error) {
+	acc := seed
+	for r := range source {
+		if r.Err != nil {
+			Stop(source)
+			return acc, r.Err
+		}
+		next, err := accumulate(acc, r.Value)
+		if err != nil {
+			Stop(source)
+			return acc, err
+		}
+		acc = next
+	}
+	return acc, nil
+}
+
+// Sends the value of each successful Result received on a channel on a new channel,
+// routing the errors of failed Results to the given sink instead.
+// A nil sink drops the errors.
+func Unwrap[T any](source <-chan Result[T], sink func(error), options ...Option) <-chan T {
+	if source == nil {
+		return nil
+	}
+	output, ctx, done := newConfiguredStage[T](context.Background(), configure(options), source)
+	go func() {
+		defer done()
+		for r := range source {
+			if r.Err != nil {
+				if sink != nil {
+					sink(r.Err)
+				}
+				continue
+			}
+			if !send(ctx, output, r.Value) {
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// Routes each failed Result received on a channel to a new channel for its class of error
+// (say "retryable", "fatal" or "validation"), so that each class can have its own handling downstream.
+// The classes are tried in order of name, and a failed Result goes to the first
+// whose test returns true for its error. Successful Results, and failed Results
+// that match no class, go to a fallthrough channel.
+// Returns a channel per class and the fallthrough channel;
+// as with PartitionN, every channel must be drained or stopped.
+func ClassifyErrors[T any](source <-chan Result[T], classes map[string]func(error) bool, options ...Option) (map[string]<-chan Result[T], <-chan Result[T]) {
+	if source == nil {
+		return nil, nil
+	}
+	names := slices.Sorted(maps.Keys(classes))
+	partitions, rest := PartitionN(source, func(r Result[T]) int {
+		if r.Err == nil {
+			return -1
+		}
+		for i, name := range names {
+			if classes[name](r.Err) {
+				return i
+			}
+		}
+		return -1
+	}, len(names), options...)
+	outputs := make(map[string]<-chan Result[T], len(names))
+	for i, name := range names {
+		outputs[name] = partitions[i]
+	}
+	return outputs, rest
+}
+
+// Listens on a channel of Results until it is closed
+// and returns the values received in a slice,
+// or stops at the first failed Result and returns the values so far along with its error
+func ToSliceOrError[T any](source <-chan Result[T]) ([]T, error) {
+	return TryReduce(source, []T(nil), func(values []T, value T) ([]T, error) {
+		return append(values, value), nil
+	})
+}
@@ -0,0 +1,163 @@
+package gl
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// Subscribes to the channel returned by the given factory and forwards its values.
+// If the factory fails, it is called again, up to attempts times in all,
+// waiting backoff before the second attempt and twice as long before each attempt after that.
+// The channel closes once a subscription closes, or once every attempt has failed;
+// use RetryResults to find out why the attempts failed.
+func Retry[T any](factory func() (<-chan T, error), attempts int, backoff time.Duration) <-chan T {
+	return Unwrap(RetryResults(func() (<-chan Result[T], error) {
+		source, err := factory()
+		if err != nil {
+			return nil, err
+		}
+		return Try(source), nil
+	}, attempts, backoff), nil)
+}
+
+// Like Retry, but for an error-aware source: a failed Result also counts as a failure,
+// upon which the source is stopped and the factory called again for a fresh one.
+// The failed Results of attempts that are retried are not forwarded;
+// once every attempt has failed, the last error is sent as a failed Result.
+// Values received before a failure are forwarded,
+// so a source that starts over from the beginning may send them again.
+func RetryResults[T any](factory func() (<-chan Result[T], error), attempts int, backoff time.Duration) <-chan Result[T] {
+	output, ctx, done := newStage[Result[T]](context.Background())
+	forward := func(source <-chan Result[T]) error {
+		for {
+			s, ok := receive(ctx, source)
+			if !ok {
+				if ctx.Err() != nil {
+					Stop(source)
+				}
+				return ctx.Err()
+			}
+			if s.Err != nil {
+				Stop(source)
+				return s.Err
+			}
+			if !send(ctx, output, s) {
+				Stop(source)
+				return ctx.Err()
+			}
+		}
+	}
+	go func() {
+		defer done()
+		delay := backoff
+		for attempt := 1; ; attempt++ {
+			source, err := factory()
+			if err == nil {
+				if err = forward(source); err == nil || ctx.Err() != nil {
+					return
+				}
+			}
+			if attempt >= attempts {
+				send(ctx, output, Fail[T](err))
+				return
+			}
+			if !sleep(ctx, delay) {
+				return
+			}
+			delay *= 2
+		}
+	}()
+	return output
+}
+
+// How long Resubscribe waits between failed subscriptions.
+// Zero fields take the defaults noted.
+type BackoffPolicy struct {
+	Initial     time.Duration // wait after the first failure; default 100ms
+	Max         time.Duration // longest wait; default 30s
+	Multiplier  float64       // growth of the wait after each further failure; default 2
+	Jitter      float64       // fraction of each wait randomly taken off, from 0 to 1; default 0
+	MaxAttempts int           // consecutive failures after which to give up; default 0, never
+}
+
+// Returns the wait after the given number of consecutive failures (at least one)
+func (p BackoffPolicy) Delay(failures int) time.Duration {
+	initial, maxDelay, multiplier := p.Initial, p.Max, p.Multiplier
+	if initial <= 0 {
+		initial = 100 * time.Millisecond
+	}
+	if maxDelay <= 0 {
+		maxDelay = 30 * time.Second
+	}
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	delay := float64(initial)
+	for i := 1; i < failures && delay < float64(maxDelay); i++ {
+		delay *= multiplier
+	}
+	delay = min(delay, float64(maxDelay))
+	if jitter := min(max(p.Jitter, 0), 1); jitter > 0 {
+		delay -= delay * jitter * rand.Float64()
+	}
+	return time.Duration(delay)
+}
+
+// Subscribes to the error-aware source returned by the given factory
+// and forwards its Results. If the factory fails or the source sends a failed Result
+// (a dropped connection, an expired cursor), the source is stopped and,
+// after waiting as the policy says, the factory is called again for a fresh one;
+// the failures themselves are not forwarded.
+// Once the policy's MaxAttempts consecutive failures have happened,
+// the last error is sent as a failed Result and the stream ends.
+// The stream also ends when a subscription closes.
+// The factory's context is cancelled when the stream is stopped.
+func Resubscribe[T any](factory func(ctx context.Context) (<-chan Result[T], error), policy BackoffPolicy) <-chan Result[T] {
+	return ResubscribeFrom(func(ctx context.Context, _ T, _ bool) (<-chan Result[T], error) {
+		return factory(ctx)
+	}, policy)
+}
+
+// Like Resubscribe, but the factory is also given the last value forwarded
+// and whether there was one, so that a fresh subscription can resume after it
+func ResubscribeFrom[T any](factory func(ctx context.Context, last T, resumed bool) (<-chan Result[T], error), policy BackoffPolicy) <-chan Result[T] {
+	output, ctx, done := newStage[Result[T]](context.Background())
+	go func() {
+		defer done()
+		var last T
+		resumed := false
+		failures := 0
+		for {
+			source, err := factory(ctx, last, resumed)
+			for err == nil {
+				s, ok := receive(ctx, source)
+				switch {
+				case !ok:
+					if ctx.Err() != nil {
+						Stop(source)
+					}
+					return
+				case s.Err != nil:
+					Stop(source)
+					err = s.Err
+				case !send(ctx, output, s):
+					Stop(source)
+					return
+				default:
+					last, resumed = s.Value, true
+					failures = 0
+				}
+			}
+			failures++
+			if policy.MaxAttempts > 0 && failures >= policy.MaxAttempts {
+				send(ctx, output, Fail[T](err))
+				return
+			}
+			if !sleep(ctx, policy.Delay(failures)) {
+				return
+			}
+		}
+	}()
+	return output
+}
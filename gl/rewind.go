@@ -0,0 +1,65 @@
+package gl
+
+import "iter"
+
+// A consumer of a channel that keeps the last n values it has read
+// and can back up over them, so that they can be read again
+// (for instance, to re-parse them a different way after an error).
+// A Rewindable is meant for a single consumer and is not safe for concurrent use.
+type Rewindable[T any] struct {
+	source  <-chan T
+	history *ring[T]
+	behind  int // how many values from the end of history Next returns before receiving again
+}
+
+// Wraps a channel in a Rewindable that keeps the last n = count values read
+func NewRewindable[T any](source <-chan T, count int) *Rewindable[T] {
+	return &Rewindable[T]{source: source, history: newRing[T](count)}
+}
+
+// Returns the next value and true: a value backed up over by Rewind if there are any,
+// or else the next value received from the channel.
+// Returns the zero value and false once the channel is closed.
+func (r *Rewindable[T]) Next() (T, bool) {
+	if r.behind > 0 {
+		value := r.history.at(r.history.size - r.behind)
+		r.behind--
+		return value, true
+	}
+	value, ok := <-r.source
+	if ok {
+		r.history.push(value)
+	}
+	return value, ok
+}
+
+// Backs up over the last n values read, so that Next returns them again, in order.
+// Cannot back up past the oldest value kept; returns how many values it backed up.
+func (r *Rewindable[T]) Rewind(n int) int {
+	n = min(max(n, 0), r.history.size-r.behind)
+	r.behind += n
+	return n
+}
+
+// Returns how many values Rewind could back up over from here
+func (r *Rewindable[T]) Buffered() int {
+	return r.history.size - r.behind
+}
+
+// Returns an iterator over the values Next returns, until the channel is closed.
+// Breaking out of the loop leaves the Rewindable where it is, so it can be rewound and ranged over again.
+func (r *Rewindable[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for {
+			value, ok := r.Next()
+			if !ok || !yield(value) {
+				return
+			}
+		}
+	}
+}
+
+// Stops the wrapped channel
+func (r *Rewindable[T]) Stop() {
+	Stop(r.source)
+}
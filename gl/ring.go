@@ -0,0 +1,90 @@
+package gl
+
+// A fixed-capacity buffer that keeps the most recently pushed values,
+// overwriting the oldest once full
+type ring[T any] struct {
+	buf       []T
+	start     int
+	size      int
+	unbounded bool // never full: keeps every value pushed
+}
+
+func newRing[T any](capacity int) *ring[T] {
+	return &ring[T]{buf: make([]T, max(capacity, 0))}
+}
+
+// Creates a buffer with no capacity limit, which keeps every value pushed
+func newUnboundedRing[T any]() *ring[T] {
+	return &ring[T]{unbounded: true}
+}
+
+// Adds a value to the buffer. If the buffer was full,
+// the oldest value is evicted and returned along with true.
+func (r *ring[T]) push(value T) (T, bool) {
+	var evicted T
+	if r.unbounded {
+		r.buf = append(r.buf, value)
+		r.size++
+		return evicted, false
+	}
+	if len(r.buf) == 0 {
+		return value, true
+	}
+	if r.size < len(r.buf) {
+		r.buf[(r.start+r.size)%len(r.buf)] = value
+		r.size++
+		return evicted, false
+	}
+	evicted = r.buf[r.start]
+	r.buf[r.start] = value
+	r.start = (r.start + 1) % len(r.buf)
+	return evicted, true
+}
+
+// Returns a copy of the buffered values, oldest first
+func (r *ring[T]) values() []T {
+	out := make([]T, r.size)
+	for i := range out {
+		out[i] = r.buf[(r.start+i)%len(r.buf)]
+	}
+	return out
+}
+
+// Returns the oldest buffered value and true if the buffer is full,
+// or the zero value and false otherwise
+func (r *ring[T]) oldestIfFull() (T, bool) {
+	if r.size == 0 || r.size < len(r.buf) {
+		var zero T
+		return zero, false
+	}
+	return r.buf[r.start], true
+}
+
+// Removes and returns the oldest buffered value and true,
+// or the zero value and false if the buffer is empty
+func (r *ring[T]) pop() (T, bool) {
+	var zero T
+	if r.size == 0 {
+		return zero, false
+	}
+	if r.unbounded {
+		oldest := r.buf[0]
+		r.buf[0] = zero
+		r.buf = r.buf[1:]
+		r.size--
+		return oldest, true
+	}
+	oldest := r.buf[r.start]
+	r.buf[r.start] = zero
+	r.start = (r.start + 1) % len(r.buf)
+	r.size--
+	return oldest, true
+}
+
+// Returns the i-th buffered value, counting from the oldest
+func (r *ring[T]) at(i int) T {
+	if r.unbounded {
+		return r.buf[i]
+	}
+	return r.buf[(r.start+i)%len(r.buf)]
+}
@@ -0,0 +1,84 @@
+package gl
+
+import "context"
+
+// Set operators
+
+// Sends the distinct values from the first channel and then
+// those from the second channel that have not already been sent
+func Union[T comparable](first <-chan T, second <-chan T) <-chan T {
+	if first == nil || second == nil {
+		return nil
+	}
+	output, ctx, done := newStage[T](context.Background(), first, second)
+	go func() {
+		defer done()
+		seen := make(map[T]struct{})
+		for _, source := range []<-chan T{first, second} {
+			for s := range source {
+				if _, dup := seen[s]; !dup {
+					seen[s] = struct{}{}
+					if !send(ctx, output, s) {
+						return
+					}
+				}
+			}
+		}
+	}()
+	return output
+}
+
+// Sends each distinct value received on a channel on a new channel,
+// the first time it is received
+func Distinct[T comparable](source <-chan T, options ...Option) <-chan T {
+	seen := make(map[T]struct{})
+	return Filter(source, func(s T) bool {
+		if _, dup := seen[s]; dup {
+			return false
+		}
+		seen[s] = struct{}{}
+		return true
+	}, options...)
+}
+
+// Receives every value from the second channel,
+// then sends the distinct values from the first channel
+// that were also received on the second
+func Intersect[T comparable](first <-chan T, second <-chan T) <-chan T {
+	return setFilter(first, second, true)
+}
+
+// Receives every value from the second channel,
+// then sends the distinct values from the first channel
+// that were not received on the second
+func Except[T comparable](first <-chan T, second <-chan T) <-chan T {
+	return setFilter(first, second, false)
+}
+
+// Sends the distinct values of first whose membership in second is as given
+func setFilter[T comparable](first <-chan T, second <-chan T, member bool) <-chan T {
+	if first == nil || second == nil {
+		return nil
+	}
+	output, ctx, done := newStage[T](context.Background(), first, second)
+	go func() {
+		defer done()
+		others := make(map[T]struct{})
+		for s := range second {
+			others[s] = struct{}{}
+		}
+		seen := make(map[T]struct{})
+		for s := range first {
+			if _, dup := seen[s]; dup {
+				continue
+			}
+			seen[s] = struct{}{}
+			if _, in := others[s]; in == member {
+				if !send(ctx, output, s) {
+					return
+				}
+			}
+		}
+	}()
+	return output
+}
@@ -0,0 +1,261 @@
+package gl
+
+import (
+	"context"
+	"hash/maphash"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Sharded stateful operators.
+// A stateful operator keeping one map on one goroutine becomes the bottleneck
+// once there are many distinct keys. The operators below split the keys across
+// several shards, each with its own goroutine and its own map, so the work spreads
+// over several cores. Every key always goes to the same shard,
+// so the shards' results never overlap and are merged by simply combining them.
+
+// How many values may wait for each shard before the dispatcher blocks
+const shardBuffer = 64
+
+// Seed for hashing keys to shards
+var shardSeed = maphash.MakeSeed()
+
+// Returns the shard, out of n, that owns the given key
+func shardOf[K comparable](key K, n int) int {
+	if n == 1 {
+		return 0
+	}
+	var h uint64
+	switch k := any(key).(type) {
+	case string:
+		h = maphash.String(shardSeed, k)
+	case int:
+		h = uint64(k) * 0x9E3779B97F4A7C15 >> 32
+	case int64:
+		h = uint64(k) * 0x9E3779B97F4A7C15 >> 32
+	case uint64:
+		h = k * 0x9E3779B97F4A7C15 >> 32
+	default:
+		h = maphash.Comparable(shardSeed, key)
+	}
+	return int(h % uint64(n))
+}
+
+// Returns the number of shards to use for the given count and options:
+// the count itself (at least one), or with WithAutoParallel,
+// one per GOMAXPROCS, up to the count if it is positive
+func shardCount(shards int, config stageConfig) int {
+	if config.autoParallel {
+		n := runtime.GOMAXPROCS(0)
+		if shards > 0 {
+			n = min(n, shards)
+		}
+		return n
+	}
+	return max(shards, 1)
+}
+
+// Like Distinct, but the values are split across the given number of shards,
+// each remembering the values it owns on its own goroutine.
+// Values are sent as soon as a shard finds them new, so the order is not kept.
+// Pass WithAutoParallel to use one shard per GOMAXPROCS.
+func DistinctSharded[T comparable](source <-chan T, shards int, options ...Option) <-chan T {
+	if source == nil {
+		return nil
+	}
+	config := configure(options)
+	n := shardCount(shards, config)
+	output, ctx, done := newConfiguredStage[T](context.Background(), config, source)
+	ins := make([]chan T, n)
+	var running sync.WaitGroup
+	for i := range ins {
+		ins[i] = NewBuffered[T](shardBuffer)
+		running.Add(1)
+		go func() {
+			defer running.Done()
+			seen := make(map[T]struct{})
+			for s := range ins[i] {
+				if _, dup := seen[s]; dup {
+					continue
+				}
+				seen[s] = struct{}{}
+				if !send(ctx, output, s) {
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		defer func() {
+			for _, in := range ins {
+				close(in)
+			}
+			running.Wait()
+			done()
+		}()
+		for {
+			s, ok := receive(ctx, source)
+			if !ok || !send(ctx, ins[shardOf(s, n)], s) {
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// Listens on a channel until it is closed, folding each value into an accumulator
+// for its key (starting from the given seed), and returns the accumulator for every key
+func FoldByKey[T any, K comparable, A any](source <-chan T, key func(T) K, seed A, fold func(A, T) A) map[K]A {
+	folded := make(map[K]A)
+	for s := range source {
+		k := key(s)
+		acc, ok := folded[k]
+		if !ok {
+			acc = seed
+		}
+		folded[k] = fold(acc, s)
+	}
+	return folded
+}
+
+// A message to a folding shard: a value to fold in under its key,
+// or, if flush is not nil, a request for the shard's accumulators
+type foldMessage[T any, K comparable, A any] struct {
+	key   K
+	value T
+	flush chan map[K]A
+}
+
+// Starts n shards that fold the values sent to them by key.
+// A shard replies to a flush with its accumulators and starts afresh;
+// it exits once its channel is closed.
+// With WithRecover, a shard whose fold panics records it and calls abort.
+func startFoldShards[T any, K comparable, A any](n int, seed A, fold func(A, T) A, config stageConfig, abort func()) []chan foldMessage[T, K, A] {
+	ins := make([]chan foldMessage[T, K, A], n)
+	for i := range ins {
+		ins[i] = NewBuffered[foldMessage[T, K, A]](shardBuffer)
+		go func() {
+			if config.recover != nil {
+				defer func() {
+					if r := recover(); r != nil {
+						config.recover.record(newPanicError(r))
+						abort()
+					}
+				}()
+			}
+			folded := make(map[K]A)
+			for m := range ins[i] {
+				if m.flush != nil {
+					m.flush <- folded
+					folded = make(map[K]A)
+					continue
+				}
+				acc, ok := folded[m.key]
+				if !ok {
+					acc = seed
+				}
+				folded[m.key] = fold(acc, m.value)
+			}
+		}()
+	}
+	return ins
+}
+
+// Collects and combines the accumulators of every shard, which start afresh.
+// Reports false if the context is done first.
+func flushFoldShards[T any, K comparable, A any](ctx context.Context, ins []chan foldMessage[T, K, A]) (map[K]A, bool) {
+	merged := make(map[K]A)
+	for _, in := range ins {
+		reply := NewBuffered[map[K]A](1)
+		if !send(ctx, in, foldMessage[T, K, A]{flush: reply}) {
+			return nil, false
+		}
+		folded, ok := receive(ctx, reply)
+		if !ok {
+			return nil, false
+		}
+		for k, acc := range folded {
+			merged[k] = acc
+		}
+	}
+	return merged, true
+}
+
+// Like FoldByKey, but the keys are split across the given number of shards,
+// each folding the keys it owns on its own goroutine.
+// Pass WithAutoParallel to use one shard per GOMAXPROCS.
+// With WithRecover, if fold panics, the channel is stopped and nil is returned.
+func FoldByKeySharded[T any, K comparable, A any](source <-chan T, key func(T) K, seed A, fold func(A, T) A, shards int, options ...Option) map[K]A {
+	config := configure(options)
+	n := shardCount(shards, config)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ins := startFoldShards[T, K](n, seed, fold, config, cancel)
+	defer func() {
+		for _, in := range ins {
+			close(in)
+		}
+	}()
+	for s := range source {
+		k := key(s)
+		if !send(ctx, ins[shardOf(k, n)], foldMessage[T, K, A]{key: k, value: s}) {
+			Stop(source)
+			return nil
+		}
+	}
+	folded, _ := flushFoldShards(ctx, ins)
+	return folded
+}
+
+// Like FoldByKeySharded, but runs in tumbling windows of the given duration:
+// at the end of each window, the accumulators for the keys seen in it
+// are sent on a new channel and every key starts again from the seed.
+// Windows in which nothing was received are skipped.
+// When the source closes, the accumulators of the last, partial window are sent.
+// A window of zero or less is taken as one millisecond.
+// Pass WithAutoParallel to use one shard per GOMAXPROCS.
+func WindowFoldByKeySharded[T any, K comparable, A any](source <-chan T, key func(T) K, seed A, fold func(A, T) A, window time.Duration, shards int, options ...Option) <-chan map[K]A {
+	if source == nil {
+		return nil
+	}
+	config := configure(options)
+	n := shardCount(shards, config)
+	window = tickPeriod(window)
+	output, ctx, done := newConfiguredStage[map[K]A](context.Background(), config, source)
+	go func() {
+		defer done()
+		ins := startFoldShards[T, K](n, seed, fold, config, func() { Stop((<-chan map[K]A)(output)) })
+		defer func() {
+			for _, in := range ins {
+				close(in)
+			}
+		}()
+		ticker := time.NewTicker(window)
+		defer ticker.Stop()
+		emit := func() bool {
+			folded, ok := flushFoldShards(ctx, ins)
+			return ok && (len(folded) == 0 || send(ctx, output, folded))
+		}
+		for {
+			select {
+			case s, ok := <-source:
+				if !ok {
+					emit()
+					return
+				}
+				k := key(s)
+				if !send(ctx, ins[shardOf(k, n)], foldMessage[T, K, A]{key: k, value: s}) {
+					return
+				}
+			case <-ticker.C:
+				if !emit() {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return output
+}
@@ -0,0 +1,225 @@
+package gl
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// A Stager receives output in a staging location (a temp file, a staging table)
+// and either makes it visible all at once or discards it
+type Stager interface {
+	io.Writer
+	// Atomically publishes everything written so far
+	Commit() error
+	// Discards everything written so far
+	Abort() error
+}
+
+// Writes each value received on a channel to the given stager with the given write function.
+// Once the channel closes, the staged output is committed.
+// If any write fails, the staged output is aborted and the write error is returned,
+// so a partially processed run never leaves half-written output behind.
+func CommitOnClose[T any](source <-chan T, stager Stager, write func(io.Writer, T) error) error {
+	for s := range source {
+		if err := write(stager, s); err != nil {
+			Stop(source)
+			stager.Abort()
+			return err
+		}
+	}
+	return stager.Commit()
+}
+
+// A Stager that writes to a temporary file in the same directory as its target
+// and renames it over the target on Commit
+type FileStager struct {
+	*os.File
+	target string
+}
+
+// Creates a FileStager for the file at the given path.
+// Nothing is written to the path itself until Commit.
+func StageFile(path string) (*FileStager, error) {
+	dir, name := filepath.Split(path)
+	temp, err := os.CreateTemp(dir, "."+name+".staging-*")
+	if err != nil {
+		return nil, err
+	}
+	return &FileStager{File: temp, target: path}, nil
+}
+
+func (f *FileStager) Commit() error {
+	if err := f.File.Sync(); err != nil {
+		f.Abort()
+		return err
+	}
+	if err := f.File.Close(); err != nil {
+		os.Remove(f.File.Name())
+		return err
+	}
+	if err := os.Rename(f.File.Name(), f.target); err != nil {
+		os.Remove(f.File.Name())
+		return err
+	}
+	return nil
+}
+
+func (f *FileStager) Abort() error {
+	f.File.Close()
+	return os.Remove(f.File.Name())
+}
+
+// When RotateSink moves on to a new output.
+// Limits that are zero or less do not apply.
+type RotatePolicy struct {
+	MaxItems int           // values written to one output
+	MaxBytes int64         // bytes written to one output; an output may exceed it by one value
+	MaxAge   time.Duration // time since an output was opened
+}
+
+// Writes each value received on a channel with the given write function
+// to a series of outputs, each staged by a Stager from the given factory
+// (which is passed 0 for the first output, 1 for the second, and so on)
+// and committed once the policy says to rotate, or once the channel closes,
+// so that log and archive pipelines produce bounded files that appear whole.
+// An output is only opened once there is a value to write to it.
+// For files renamed into place on commit:
+//
+//	RotateSink(lines, func(i int) (Stager, error) {
+//		return StageFile(fmt.Sprintf("archive-%05d.log", i))
+//	}, write, RotatePolicy{MaxBytes: 64 << 20, MaxAge: time.Hour})
+//
+// If opening, writing or committing fails, the current output is aborted,
+// the channel is stopped, and the error is returned;
+// outputs committed before then are kept.
+func RotateSink[T any](source <-chan T, next func(index int) (Stager, error), write func(io.Writer, T) error, policy RotatePolicy) error {
+	var current Stager
+	var counter *countingWriter
+	items, index := 0, 0
+	fail := func(err error) error {
+		Stop(source)
+		if current != nil {
+			current.Abort()
+		}
+		return err
+	}
+	rotate := func() error {
+		err := current.Commit()
+		current = nil
+		return err
+	}
+	var expired <-chan time.Time
+	var timer *time.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+	for {
+		select {
+		case s, ok := <-source:
+			if !ok {
+				if current != nil {
+					return rotate()
+				}
+				return nil
+			}
+			if current == nil {
+				stager, err := next(index)
+				if err != nil {
+					return fail(err)
+				}
+				current, counter, items = stager, &countingWriter{w: stager}, 0
+				index++
+				if policy.MaxAge > 0 {
+					if timer == nil {
+						timer = time.NewTimer(policy.MaxAge)
+					} else {
+						timer.Reset(policy.MaxAge)
+					}
+					expired = timer.C
+				}
+			}
+			if err := write(counter, s); err != nil {
+				return fail(err)
+			}
+			items++
+			if (policy.MaxItems > 0 && items >= policy.MaxItems) || (policy.MaxBytes > 0 && counter.n >= policy.MaxBytes) {
+				expired = nil
+				if err := rotate(); err != nil {
+					return fail(err)
+				}
+			}
+		case <-expired:
+			expired = nil
+			if err := rotate(); err != nil {
+				return fail(err)
+			}
+		}
+	}
+}
+
+// A writer that counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// A transaction on some transactional resource (a database, a message broker, ...)
+type Tx interface {
+	Commit() error
+	Rollback() error
+}
+
+// Receives values from a channel in batches of up to n = batch values
+// (a batch of zero or less is taken as one) and processes each batch inside its own transaction:
+// begin is called, then do, then the transaction is committed,
+// or rolled back if do returns an error.
+// A failed batch is retried in a new transaction up to the given number of attempts in total.
+// Returns nil once the channel closes and every batch has been committed,
+// or the last error of the first batch that could not be committed.
+func ProcessInTransactions[T any](source <-chan T, batch int, do func(tx Tx, items []T) error, begin func() (Tx, error), attempts int) error {
+	batch = max(batch, 1)
+	items := make([]T, 0, batch)
+	for s := range source {
+		items = append(items, s)
+		if len(items) >= batch {
+			if err := processBatch(items, do, begin, attempts); err != nil {
+				Stop(source)
+				return err
+			}
+			items = make([]T, 0, batch)
+		}
+	}
+	if len(items) > 0 {
+		return processBatch(items, do, begin, attempts)
+	}
+	return nil
+}
+
+func processBatch[T any](items []T, do func(tx Tx, items []T) error, begin func() (Tx, error), attempts int) error {
+	var err error
+	for attempt := 0; attempt < max(attempts, 1); attempt++ {
+		var tx Tx
+		tx, err = begin()
+		if err != nil {
+			continue
+		}
+		if err = do(tx, items); err != nil {
+			tx.Rollback()
+			continue
+		}
+		if err = tx.Commit(); err == nil {
+			return nil
+		}
+	}
+	return err
+}
@@ -0,0 +1,177 @@
+package gl
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// One message from a live feed of events
+type Event struct {
+	ID    string // the event's ID, if the server gave one
+	Type  string // the event's type; "message" unless the server said otherwise
+	Data  string
+	Retry time.Duration // the reconnection delay the server asked for, if any
+}
+
+// A connection to a live feed of events, such as a Server-Sent Events stream
+// or a WebSocket, for use with FromEventStream.
+// Next blocks until the next event arrives and returns io.EOF once the feed ends;
+// Close may be called while Next is blocked, and makes it return.
+type EventStream interface {
+	Next() (Event, error)
+	Close() error
+}
+
+// Receives events from the given stream and sends them on a new channel
+// as successful Results, closing the stream and the channel once it ends.
+// An error from the stream other than io.EOF is sent as a failed Result first.
+func FromEventStream(stream EventStream, options ...Option) <-chan Result[Event] {
+	return FromEventStreamCtx(context.Background(), stream, options...)
+}
+
+// Context-aware FromEventStream
+func FromEventStreamCtx(ctx context.Context, stream EventStream, options ...Option) <-chan Result[Event] {
+	output, ctx, done := newConfiguredStage[Result[Event]](ctx, configure(options))
+	go func() {
+		defer done()
+		sendEvents(ctx, output, stream)
+	}()
+	return output
+}
+
+// Sends the events of the given stream on output until it ends or ctx is done,
+// then closes it
+func sendEvents(ctx context.Context, output chan<- Result[Event], stream EventStream) {
+	unblock := context.AfterFunc(ctx, func() { stream.Close() })
+	defer func() {
+		if unblock() {
+			stream.Close()
+		}
+	}()
+	for {
+		event, err := stream.Next()
+		if err != nil {
+			if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+				send(ctx, output, Fail[Event](err))
+			}
+			return
+		}
+		if !send(ctx, output, Ok(event)) {
+			return
+		}
+	}
+}
+
+// Connects to the Server-Sent Events stream at the given URL
+// and sends its events on a new channel as successful Results,
+// so that a live feed can be filtered, windowed and aggregated.
+// If the connection cannot be made or breaks, the error is sent as a failed Result
+// and the channel closes; to reconnect, use ResubscribeFrom with FromSSEWith,
+// passing the ID of the last event received as the Last-Event-ID header.
+func FromSSE(url string, options ...Option) <-chan Result[Event] {
+	return FromSSECtx(context.Background(), url, options...)
+}
+
+// Context-aware FromSSE
+func FromSSECtx(ctx context.Context, url string, options ...Option) <-chan Result[Event] {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return FromCtx(ctx, []Result[Event]{Fail[Event](fmt.Errorf("gl: connecting to event stream: %w", err))}, options...)
+	}
+	return FromSSEWith(http.DefaultClient, request, options...)
+}
+
+// Like FromSSE, but sends the given request, whose context controls the stream,
+// with the given client, so that headers such as authorization or Last-Event-ID can be set.
+// The connection is made on the channel's own goroutine, so this returns at once.
+func FromSSEWith(client *http.Client, request *http.Request, options ...Option) <-chan Result[Event] {
+	output, ctx, done := newConfiguredStage[Result[Event]](request.Context(), configure(options))
+	go func() {
+		defer done()
+		// Stopping the channel cancels the request, and with it the dial
+		stream, err := DialSSE(client, request.WithContext(ctx))
+		if err != nil {
+			if ctx.Err() == nil {
+				send(ctx, output, Fail[Event](err))
+			}
+			return
+		}
+		sendEvents(ctx, output, stream)
+	}()
+	return output
+}
+
+// An EventStream reading Server-Sent Events from an HTTP response
+type SSEStream struct {
+	body   io.ReadCloser
+	reader *bufio.Reader
+}
+
+// Sends the given request with the given client, asking for an event stream,
+// and returns the stream once the server has accepted it
+func DialSSE(client *http.Client, request *http.Request) (*SSEStream, error) {
+	request.Header.Set("Accept", "text/event-stream")
+	request.Header.Set("Cache-Control", "no-cache")
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("gl: connecting to event stream: %w", err)
+	}
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		return nil, fmt.Errorf("gl: connecting to event stream: %s", response.Status)
+	}
+	return &SSEStream{body: response.Body, reader: bufio.NewReader(response.Body)}, nil
+}
+
+// Reads the next event, skipping comments and fields it does not know
+func (s *SSEStream) Next() (Event, error) {
+	event := Event{Type: "message"}
+	var data []string
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			// An event cut off by the end of the stream is discarded
+			return Event{}, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			if data == nil {
+				event = Event{Type: "message"}
+				continue
+			}
+			event.Data = strings.Join(data, "\n")
+			return event, nil
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			if value != "" {
+				event.Type = value
+			}
+		case "data":
+			data = append(data, value)
+		case "id":
+			event.ID = value
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil {
+				event.Retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
+
+// Closes the connection
+func (s *SSEStream) Close() error {
+	return s.body.Close()
+}
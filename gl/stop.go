@@ -0,0 +1,89 @@
+package gl
+
+import (
+	"context"
+	"sync"
+)
+
+// Early termination.
+// Every operator registers its output channel along with a way to stop it.
+// When an operator or terminal is done with a channel before it has closed
+// (Take once it has taken enough, First once it has its element, ...),
+// it stops the operator producing that channel, which in turn stops the operators
+// upstream of it, so that no goroutine is left blocked on a send nobody will receive
+// and generators such as Fibonaccis stop producing.
+//
+// Every generator source (From, Range, Repeat, Generate, Fibonaccis, FromSeq, ...)
+// follows the same shutdown contract: it is stopped with Stop,
+// or by cancelling the context given to its Ctx variant.
+// Either way its goroutine exits and its channel is closed.
+// Callers must never close a channel returned by this package themselves.
+
+// Functions that stop running operators, keyed by their output channels
+var stoppers sync.Map
+
+// Creates the output channel of an operator that receives from the given upstream channels,
+// and registers it so that it can be stopped from downstream.
+// The returned context is cancelled when the operator is stopped.
+// The returned done function must be called when the operator's goroutine exits;
+// it closes the output channel and stops the upstream operators.
+func newStage[T any](parent context.Context, upstream ...any) (chan T, context.Context, func()) {
+	return newConfiguredStage[T](parent, stageConfig{}, upstream...)
+}
+
+// Like newStage, but set up according to the operator's options.
+// With WithRecover, done must be deferred directly by the operator's goroutine,
+// so that it can recover a panic there.
+func newConfiguredStage[T any](parent context.Context, config stageConfig, upstream ...any) (chan T, context.Context, func()) {
+	output := NewBuffered[T](config.buffer)
+	ctx, cancel := context.WithCancel(parent)
+	onStop(output, func() {
+		cancel()
+		stopAll(upstream)
+	})
+	done := func() {
+		if config.recover != nil {
+			if r := recover(); r != nil {
+				config.recover.record(newPanicError(r))
+			}
+		}
+		forgetStop(output)
+		cancel()
+		close(output)
+		stopAll(upstream)
+	}
+	return output, ctx, done
+}
+
+// Registers a function that stops whatever produces the given channel.
+// Channels are registered by their receive-only form, which is what callers hold.
+func onStop[T any](output chan T, stop func()) {
+	stoppers.Store((<-chan T)(output), stop)
+}
+
+// Removes the registration made by onStop
+func forgetStop[T any](output chan T) {
+	stoppers.Delete((<-chan T)(output))
+}
+
+// Stops the operator producing the given channel and all operators upstream of it;
+// the channel is closed shortly afterwards.
+// Use this to abandon a pipeline without receiving everything from it.
+// Has no effect on channels that were not produced by this package.
+// A channel handed to an operator belongs to that operator,
+// so it is stopped too once that operator is finished with it.
+func Stop[T any](source <-chan T) {
+	stopChannel(source)
+}
+
+func stopChannel(source any) {
+	if stop, ok := stoppers.LoadAndDelete(source); ok {
+		stop.(func())()
+	}
+}
+
+func stopAll(sources []any) {
+	for _, source := range sources {
+		stopChannel(source)
+	}
+}
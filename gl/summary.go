@@ -0,0 +1,93 @@
+package gl
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+// How many values Summarize keeps to approximate percentiles
+const summarySampleSize = 1024
+
+// Descriptive statistics of the values received on a channel.
+// Percentiles are estimated from a uniform sample of at most 1024 values,
+// so they are exact for shorter streams and approximate for longer ones.
+// First and Last are the times the first and last values were received.
+type Summary struct {
+	Count  int
+	Sum    float64
+	Mean   float64
+	Min    float64
+	Max    float64
+	StdDev float64
+	P50    float64
+	P95    float64
+	P99    float64
+	First  time.Time
+	Last   time.Time
+}
+
+// Listens on a channel of numeric values until it is closed
+// and returns a Summary of the values received
+func Summarize[T Number](source <-chan T) Summary {
+	var summary Summary
+	var sumOfSquares float64 // of differences from the running mean
+	sample := make([]float64, 0, summarySampleSize)
+	for s := range source {
+		now := time.Now()
+		value := float64(s)
+		summary.Count++
+		if summary.Count == 1 {
+			summary.Min, summary.Max, summary.First = value, value, now
+		}
+		summary.Min = min(summary.Min, value)
+		summary.Max = max(summary.Max, value)
+		summary.Last = now
+		summary.Sum += value
+		delta := value - summary.Mean
+		summary.Mean += delta / float64(summary.Count)
+		sumOfSquares += delta * (value - summary.Mean)
+		if len(sample) < summarySampleSize {
+			sample = append(sample, value)
+		} else if i := rand.IntN(summary.Count); i < summarySampleSize {
+			sample[i] = value
+		}
+	}
+	if summary.Count == 0 {
+		return summary
+	}
+	summary.StdDev = math.Sqrt(sumOfSquares / float64(summary.Count))
+	slices.Sort(sample)
+	summary.P50 = percentile(sample, 0.50)
+	summary.P95 = percentile(sample, 0.95)
+	summary.P99 = percentile(sample, 0.99)
+	return summary
+}
+
+// Encodes the summary as a JSON object with lower-case keys.
+// The statistics and timestamps of an empty summary are null rather than zero,
+// so that a metrics backend does not mistake them for real measurements.
+func (s Summary) MarshalJSON() ([]byte, error) {
+	type stats struct {
+		Count  int        `json:"count"`
+		Sum    float64    `json:"sum"`
+		Mean   *float64   `json:"mean"`
+		Min    *float64   `json:"min"`
+		Max    *float64   `json:"max"`
+		StdDev *float64   `json:"stddev"`
+		P50    *float64   `json:"p50"`
+		P95    *float64   `json:"p95"`
+		P99    *float64   `json:"p99"`
+		First  *time.Time `json:"first"`
+		Last   *time.Time `json:"last"`
+	}
+	out := stats{Count: s.Count, Sum: s.Sum}
+	if s.Count > 0 {
+		out.Mean, out.Min, out.Max, out.StdDev = &s.Mean, &s.Min, &s.Max, &s.StdDev
+		out.P50, out.P95, out.P99 = &s.P50, &s.P95, &s.P99
+		out.First, out.Last = &s.First, &s.Last
+	}
+	return json.Marshal(out)
+}
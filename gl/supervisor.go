@@ -0,0 +1,309 @@
+package gl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// When a Supervisor restarts a pipeline that has returned
+type RestartPolicy int
+
+const (
+	// The pipeline is never restarted
+	RestartNever RestartPolicy = iota
+	// The pipeline is restarted only if it returned an error (or panicked)
+	RestartOnFailure
+	// The pipeline is restarted whenever it returns
+	RestartAlways
+)
+
+func (p RestartPolicy) String() string {
+	switch p {
+	case RestartNever:
+		return "never"
+	case RestartOnFailure:
+		return "on-failure"
+	case RestartAlways:
+		return "always"
+	}
+	return "unknown"
+}
+
+// How a Supervisor runs one pipeline
+type Supervised struct {
+	// Runs the pipeline until it finishes or ctx is cancelled, such as (*Pipeline).Run
+	Run     func(ctx context.Context) error
+	Restart RestartPolicy
+	// The wait before each restart; after MaxAttempts consecutive failures
+	// the pipeline is given up on, whatever its restart policy
+	Backoff BackoffPolicy
+	// A run lasting at least this long resets the count of consecutive failures;
+	// default 0, never
+	StableAfter time.Duration
+}
+
+// Where a supervised pipeline is in its life
+type PipelineState int
+
+const (
+	PipelineRunning PipelineState = iota
+	// Waiting to be restarted
+	PipelineRestarting
+	// Returned without error and not to be restarted
+	PipelineFinished
+	// Returned an error and not to be restarted
+	PipelineFailed
+	// Removed, or its supervisor stopped
+	PipelineStopped
+	// Not run yet
+	PipelineIdle
+	// Running, but no value has been sent for too long
+	PipelineStalled
+)
+
+func (s PipelineState) String() string {
+	switch s {
+	case PipelineRunning:
+		return "running"
+	case PipelineRestarting:
+		return "restarting"
+	case PipelineFinished:
+		return "finished"
+	case PipelineFailed:
+		return "failed"
+	case PipelineStopped:
+		return "stopped"
+	case PipelineIdle:
+		return "idle"
+	case PipelineStalled:
+		return "stalled"
+	}
+	return "unknown"
+}
+
+// A snapshot of the health of one pipeline
+type PipelineHealth struct {
+	Name      string
+	State     PipelineState
+	Since     time.Time     // when the pipeline entered its current state
+	Restarts  int           // restarts so far
+	LastError error         // the error of the last failed run, if any
+	Stages    []StageHealth // for a Pipeline, the health of its source and each stage
+}
+
+// Encodes the health as a JSON object with lower-case keys,
+// the state and error as strings, and the error null if there was none
+func (h PipelineHealth) MarshalJSON() ([]byte, error) {
+	type stage struct {
+		Name       string     `json:"name"`
+		Sent       int64      `json:"sent"`
+		LastValue  *time.Time `json:"last_value"`
+		QueueDepth int        `json:"queue_depth"`
+	}
+	type health struct {
+		Name      string    `json:"name"`
+		State     string    `json:"state"`
+		Healthy   bool      `json:"healthy"`
+		Since     time.Time `json:"since"`
+		Restarts  int       `json:"restarts"`
+		LastError *string   `json:"last_error"`
+		Stages    []stage   `json:"stages,omitempty"`
+	}
+	out := health{Name: h.Name, State: h.State.String(), Healthy: h.Healthy(), Since: h.Since, Restarts: h.Restarts}
+	if h.LastError != nil {
+		message := h.LastError.Error()
+		out.LastError = &message
+	}
+	for _, s := range h.Stages {
+		st := stage{Name: s.Name, Sent: s.Sent, QueueDepth: s.QueueDepth}
+		if !s.LastValue.IsZero() {
+			st.LastValue = &s.LastValue
+		}
+		out.Stages = append(out.Stages, st)
+	}
+	return json.Marshal(out)
+}
+
+// Reports whether the pipeline is running normally, about to run again,
+// or has finished without error
+func (h PipelineHealth) Healthy() bool {
+	return h.State == PipelineRunning || h.State == PipelineRestarting || h.State == PipelineFinished
+}
+
+// One pipeline run by a Supervisor
+type supervised struct {
+	Supervised
+	cancel context.CancelFunc
+	exited chan struct{}
+	mu     sync.Mutex
+	health PipelineHealth
+}
+
+func (p *supervised) set(state PipelineState, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.health.State = state
+	p.health.Since = time.Now()
+	if err != nil {
+		p.health.LastError = err
+	}
+	if state == PipelineRestarting {
+		p.health.Restarts++
+	}
+}
+
+func (p *supervised) snapshot() PipelineHealth {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.health
+}
+
+// Runs a set of named, long-lived pipelines, each in its own goroutine,
+// restarting those that return according to their policies,
+// and reports on their health
+type Supervisor struct {
+	mu        sync.Mutex
+	ctx       context.Context
+	stop      context.CancelFunc
+	pipelines map[string]*supervised
+}
+
+// Creates a Supervisor whose pipelines run until they are removed,
+// the Supervisor is stopped, or the given context is cancelled
+func NewSupervisor(ctx context.Context) *Supervisor {
+	ctx, stop := context.WithCancel(ctx)
+	return &Supervisor{ctx: ctx, stop: stop, pipelines: make(map[string]*supervised)}
+}
+
+// Starts running a pipeline under the given name.
+// Returns an error if the name is already taken or the Supervisor has been stopped.
+func (s *Supervisor) Add(name string, pipeline Supervised) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx.Err() != nil {
+		return fmt.Errorf("gl: supervisor stopped: %w", context.Cause(s.ctx))
+	}
+	if _, taken := s.pipelines[name]; taken {
+		return fmt.Errorf("gl: pipeline %q is already supervised", name)
+	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	p := &supervised{Supervised: pipeline, cancel: cancel, exited: make(chan struct{})}
+	p.health = PipelineHealth{Name: name, State: PipelineRunning, Since: time.Now()}
+	s.pipelines[name] = p
+	go p.supervise(ctx)
+	return nil
+}
+
+// Stops the pipeline with the given name, waits for it to return,
+// and forgets it. Reports whether there was such a pipeline.
+func (s *Supervisor) Remove(name string) bool {
+	s.mu.Lock()
+	p, ok := s.pipelines[name]
+	delete(s.pipelines, name)
+	s.mu.Unlock()
+	if ok {
+		p.cancel()
+		<-p.exited
+	}
+	return ok
+}
+
+// Stops every pipeline and waits for them all to return.
+// Their health can still be read afterwards, but no more can be added.
+func (s *Supervisor) Stop() {
+	s.mu.Lock()
+	s.stop()
+	pipelines := make([]*supervised, 0, len(s.pipelines))
+	for _, p := range s.pipelines {
+		pipelines = append(pipelines, p)
+	}
+	s.mu.Unlock()
+	for _, p := range pipelines {
+		<-p.exited
+	}
+}
+
+// Returns the health of every pipeline, sorted by name
+func (s *Supervisor) Health() []PipelineHealth {
+	s.mu.Lock()
+	health := make([]PipelineHealth, 0, len(s.pipelines))
+	for _, p := range s.pipelines {
+		health = append(health, p.snapshot())
+	}
+	s.mu.Unlock()
+	slices.SortFunc(health, func(a, b PipelineHealth) int { return strings.Compare(a.Name, b.Name) })
+	return health
+}
+
+// Reports whether every pipeline is healthy
+func (s *Supervisor) Healthy() bool {
+	return allHealthy(s.Health())
+}
+
+// Serves the health of every pipeline as a JSON array, with status 200
+// if they are all healthy and 503 otherwise
+func (s *Supervisor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	health := s.Health()
+	serveHealth(w, health, allHealthy(health))
+}
+
+func allHealthy(health []PipelineHealth) bool {
+	for _, h := range health {
+		if !h.Healthy() {
+			return false
+		}
+	}
+	return true
+}
+
+// Runs the pipeline, and again as its restart policy says, until it is done or ctx is cancelled
+func (p *supervised) supervise(ctx context.Context) {
+	defer close(p.exited)
+	failures := 0
+	for {
+		started := time.Now()
+		err := p.runOnce(ctx)
+		if ctx.Err() != nil {
+			p.set(PipelineStopped, nil)
+			return
+		}
+		if err == nil {
+			failures = 0
+			if p.Restart != RestartAlways {
+				p.set(PipelineFinished, nil)
+				return
+			}
+		} else {
+			if p.StableAfter > 0 && time.Since(started) >= p.StableAfter {
+				failures = 0
+			}
+			failures++
+			if p.Restart == RestartNever || (p.Backoff.MaxAttempts > 0 && failures >= p.Backoff.MaxAttempts) {
+				p.set(PipelineFailed, err)
+				return
+			}
+		}
+		p.set(PipelineRestarting, err)
+		if !sleep(ctx, p.Backoff.Delay(max(failures, 1))) {
+			p.set(PipelineStopped, nil)
+			return
+		}
+		p.set(PipelineRunning, nil)
+	}
+}
+
+// Runs the pipeline once, turning a panic into a PanicError
+func (p *supervised) runOnce(ctx context.Context) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = newPanicError(r)
+		}
+	}()
+	return p.Run(ctx)
+}
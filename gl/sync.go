@@ -0,0 +1,82 @@
+package gl
+
+import (
+	"context"
+	"sync"
+)
+
+// Interoperation with concurrency budgets managed elsewhere in an application.
+// The interfaces below are satisfied by *errgroup.Group and *semaphore.Weighted
+// from golang.org/x/sync, so that golinq itself does not depend on that module.
+
+// A group of goroutines whose first error is reported to whoever waits on it,
+// such as an *errgroup.Group
+type Group interface {
+	Go(f func() error)
+}
+
+// A weighted semaphore, such as a *semaphore.Weighted
+type Weighted interface {
+	Acquire(ctx context.Context, n int64) error
+	Release(n int64)
+}
+
+// Starts a goroutine in the given group that passes each value received on a channel
+// to the given sink until the channel is closed.
+// If the sink returns an error, the channel is stopped and the error
+// is returned to the group.
+func GoEach[T any](group Group, source <-chan T, sink func(T) error) {
+	group.Go(func() error {
+		for s := range source {
+			if err := sink(s); err != nil {
+				Stop(source)
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Applies the given mapper to each value from a channel, each on its own goroutine,
+// and sends the results as soon as they are ready (in no particular order).
+// Before a value is mapped, its weight is acquired from the given semaphore;
+// it is released once the result has been sent, so the number of values in flight
+// is bounded by the semaphore, which may be shared with the rest of the application.
+// A value weighing more than the semaphore's size waits until the channel is stopped.
+func ParallelMapWeighted[T1 any, T2 any](source <-chan T1, sem Weighted, weight func(T1) int64, mapper func(T1) T2, options ...Option) <-chan T2 {
+	if source == nil {
+		return nil
+	}
+	config := configure(options)
+	output, ctx, done := newConfiguredStage[T2](context.Background(), config, source)
+	go func() {
+		defer done()
+		var running sync.WaitGroup
+		defer running.Wait()
+		for {
+			s, ok := receive(ctx, source)
+			if !ok {
+				return
+			}
+			w := weight(s)
+			if sem.Acquire(ctx, w) != nil {
+				return
+			}
+			running.Add(1)
+			go func() {
+				defer running.Done()
+				defer sem.Release(w)
+				if config.recover != nil {
+					defer func() {
+						if r := recover(); r != nil {
+							config.recover.record(newPanicError(r))
+							Stop((<-chan T2)(output))
+						}
+					}()
+				}
+				send(ctx, output, mapper(s))
+			}()
+		}
+	}()
+	return output
+}
@@ -0,0 +1,92 @@
+package gl
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Follows the file at the given path like tail -f: starting from its current end,
+// sends each line appended to it (without the line ending) on a new channel
+// as a successful Result, checking for more every poll interval.
+// If the file is truncated, it is read again from the start;
+// if it is rotated (the path now names a new file), the rest of the old file is read
+// and then the new one from the start. A line is only sent once its newline is written.
+// If the file cannot be opened or read, the error is sent as a failed Result
+// and the channel closes; otherwise it stays open until stopped.
+func TailFile(path string, poll time.Duration, options ...Option) <-chan Result[string] {
+	return TailFileCtx(context.Background(), path, poll, options...)
+}
+
+// Context-aware TailFile
+func TailFileCtx(ctx context.Context, path string, poll time.Duration, options ...Option) <-chan Result[string] {
+	output, ctx, done := newConfiguredStage[Result[string]](ctx, configure(options))
+	go func() {
+		defer done()
+		file, err := os.Open(path)
+		if err != nil {
+			send(ctx, output, Fail[string](fmt.Errorf("gl: tailing file: %w", err)))
+			return
+		}
+		defer func() { file.Close() }()
+		offset, err := file.Seek(0, io.SeekEnd)
+		if err != nil {
+			send(ctx, output, Fail[string](fmt.Errorf("gl: tailing file: %w", err)))
+			return
+		}
+		reader := bufio.NewReader(file)
+		var partial strings.Builder
+		for {
+			chunk, err := reader.ReadString('\n')
+			offset += int64(len(chunk))
+			partial.WriteString(chunk)
+			if err == nil {
+				line := strings.TrimSuffix(strings.TrimSuffix(partial.String(), "\n"), "\r")
+				partial.Reset()
+				if !send(ctx, output, Ok(line)) {
+					return
+				}
+				continue
+			}
+			if !errors.Is(err, io.EOF) {
+				send(ctx, output, Fail[string](fmt.Errorf("gl: tailing file: %w", err)))
+				return
+			}
+			// At the end of the file for now; see whether it has been truncated or rotated
+			current, err := file.Stat()
+			if err != nil {
+				send(ctx, output, Fail[string](fmt.Errorf("gl: tailing file: %w", err)))
+				return
+			}
+			if current.Size() < offset {
+				if _, err := file.Seek(0, io.SeekStart); err != nil {
+					send(ctx, output, Fail[string](fmt.Errorf("gl: tailing file: %w", err)))
+					return
+				}
+				offset = 0
+				partial.Reset()
+				reader.Reset(file)
+				continue
+			}
+			// While the path is missing (between a rotation's rename and create), keep waiting
+			if named, err := os.Stat(path); err == nil && !os.SameFile(current, named) {
+				if next, err := os.Open(path); err == nil {
+					file.Close()
+					file, offset = next, 0
+					partial.Reset()
+					reader.Reset(file)
+					continue
+				}
+			}
+			if !sleep(ctx, poll) {
+				return
+			}
+		}
+	}()
+	return output
+}
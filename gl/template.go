@@ -0,0 +1,254 @@
+package gl
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Stage templates.
+// A Stage is one step of a pipeline as a value: a function from one channel to another.
+// Stages compose with Then, which is checked by the compiler.
+// A StageRegistry holds named templates that build stages from parameters,
+// so that teams can publish sets of reusable stages ("json-decode", "dedup-by-id", ...)
+// and pipelines can be assembled from configuration by name,
+// with the types of adjacent stages checked when they are assembled.
+
+// One step of a pipeline: takes a channel and returns the channel of its results
+type Stage[T1 any, T2 any] func(source <-chan T1) <-chan T2
+
+// Returns a stage that feeds the results of the first stage to the second
+func Then[T1 any, T2 any, T3 any](first Stage[T1, T2], second Stage[T2, T3]) Stage[T1, T3] {
+	return func(source <-chan T1) <-chan T3 {
+		return second(first(source))
+	}
+}
+
+// The parameters given to a stage template, such as those read from a config file
+type Params map[string]any
+
+// Returned when a stage is looked up that has not been registered
+var ErrUnknownStage = errors.New("gl: unknown stage")
+
+// Returned when the types of stages do not fit together
+var ErrStageType = errors.New("gl: mismatched stage types")
+
+// Returns the parameter with the given name, or the fallback if it is not set.
+// Numbers are converted between numeric types when no precision is lost,
+// and strings are parsed into time.Duration,
+// so parameters decoded from JSON can be read as the types stages expect.
+func Param[T any](params Params, name string, fallback T) (T, error) {
+	raw, ok := params[name]
+	if !ok || raw == nil {
+		return fallback, nil
+	}
+	if value, ok := raw.(T); ok {
+		return value, nil
+	}
+	var value T
+	target := reflect.ValueOf(&value).Elem()
+	source := reflect.ValueOf(raw)
+	switch {
+	case target.Type() == reflect.TypeFor[time.Duration]() && source.Kind() == reflect.String:
+		d, err := time.ParseDuration(source.String())
+		if err != nil {
+			return fallback, fmt.Errorf("gl: parameter %q: %w", name, err)
+		}
+		target.SetInt(int64(d))
+		return value, nil
+	case source.CanConvert(target.Type()) && isNumber(source.Kind()) && isNumber(target.Kind()):
+		converted := source.Convert(target.Type())
+		if converted.Convert(source.Type()).Equal(source) && !(isFloat(source.Kind()) && math.IsNaN(source.Float())) {
+			target.Set(converted)
+			return value, nil
+		}
+	}
+	return fallback, fmt.Errorf("gl: parameter %q: cannot use %v (%T) as %v", name, raw, raw, target.Type())
+}
+
+func isNumber(kind reflect.Kind) bool {
+	return kind >= reflect.Int && kind <= reflect.Float64
+}
+
+func isFloat(kind reflect.Kind) bool {
+	return kind == reflect.Float32 || kind == reflect.Float64
+}
+
+// A named template registered in a StageRegistry,
+// along with the types its stages receive and send
+type StageTemplate struct {
+	Name  string
+	In    reflect.Type
+	Out   reflect.Type
+	build func(Params) (Stage[any, any], error)
+	typed any // the func(Params) (Stage[In, Out], error) given to Register
+	swap  any // the *HotSwap[In, Out] given to RegisterHotSwap, if any
+}
+
+// A named set of stage templates (along with the source and sink templates
+// used by LoadPipeline), safe for concurrent use
+type StageRegistry struct {
+	mu        sync.RWMutex
+	templates map[string]*StageTemplate
+	sources   map[string]*sourceTemplate
+	sinks     map[string]*sinkTemplate
+}
+
+// Creates an empty StageRegistry
+func NewStageRegistry() *StageRegistry {
+	return &StageRegistry{
+		templates: make(map[string]*StageTemplate),
+		sources:   make(map[string]*sourceTemplate),
+		sinks:     make(map[string]*sinkTemplate),
+	}
+}
+
+// Registers a stage template under the given name.
+// Each time the template is used, build is called with the parameters given there
+// to make a new stage. Returns an error if the name is already taken.
+func Register[T1 any, T2 any](r *StageRegistry, name string, build func(Params) (Stage[T1, T2], error)) error {
+	return registerStage(r, name, build, nil)
+}
+
+// Registers a stage template as Register does, along with the HotSwap behind it, if any
+func registerStage[T1 any, T2 any](r *StageRegistry, name string, build func(Params) (Stage[T1, T2], error), swap any) error {
+	t := &StageTemplate{
+		Name: name,
+		In:   reflect.TypeFor[T1](),
+		Out:  reflect.TypeFor[T2](),
+		build: func(params Params) (Stage[any, any], error) {
+			stage, err := build(params)
+			if err != nil {
+				return nil, err
+			}
+			return eraseStage(stage), nil
+		},
+		typed: build,
+		swap:  swap,
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, taken := r.templates[name]; taken {
+		return fmt.Errorf("gl: stage %q is already registered", name)
+	}
+	r.templates[name] = t
+	return nil
+}
+
+// Builds a stage from the template registered under the given name,
+// which must receive T1 and send T2
+func Lookup[T1 any, T2 any](r *StageRegistry, name string, params Params) (Stage[T1, T2], error) {
+	t, err := r.Template(name)
+	if err != nil {
+		return nil, err
+	}
+	build, ok := t.typed.(func(Params) (Stage[T1, T2], error))
+	if !ok {
+		return nil, fmt.Errorf("%w: stage %q takes %v to %v, not %v to %v", ErrStageType, name, t.In, t.Out, reflect.TypeFor[T1](), reflect.TypeFor[T2]())
+	}
+	return build(params)
+}
+
+// Returns the template registered under the given name
+func (r *StageRegistry) Template(name string) (*StageTemplate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.templates[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownStage, name)
+	}
+	return t, nil
+}
+
+// Returns the names of all registered templates, sorted
+func (r *StageRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.templates))
+	for name := range r.templates {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// One use of a registered template: its name and the parameters to build it with
+type StageSpec struct {
+	Name   string `json:"name"`
+	Params Params `json:"params,omitempty"`
+}
+
+// Builds the stages described by the given specs and chains them in order,
+// checking that each stage sends the type the next one receives.
+// The result receives and sends untyped values; the values it is given
+// must be of the type the first stage receives.
+// With no specs, the result passes values through unchanged.
+// Also returns the types the assembled stage receives and sends (nil with no specs).
+func (r *StageRegistry) Assemble(specs ...StageSpec) (stage Stage[any, any], in reflect.Type, out reflect.Type, err error) {
+	stages, in, out, err := r.build(specs)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return func(source <-chan any) <-chan any {
+		for _, stage := range stages {
+			source = stage(source)
+		}
+		return source
+	}, in, out, nil
+}
+
+// Builds the stages described by the given specs, unchained,
+// checking their types as Assemble does
+func (r *StageRegistry) build(specs []StageSpec) (stages []Stage[any, any], in reflect.Type, out reflect.Type, err error) {
+	stages = make([]Stage[any, any], len(specs))
+	for i, spec := range specs {
+		t, err := r.Template(spec.Name)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if i == 0 {
+			in = t.In
+		} else if t.In != out {
+			return nil, nil, nil, fmt.Errorf("%w: stage %q sends %v but stage %q receives %v", ErrStageType, specs[i-1].Name, out, spec.Name, t.In)
+		}
+		out = t.Out
+		if stages[i], err = t.build(spec.Params); err != nil {
+			return nil, nil, nil, fmt.Errorf("building stage %q: %w", spec.Name, err)
+		}
+	}
+	return stages, in, out, nil
+}
+
+// Like the Assemble method, but returns a typed stage,
+// checking that the first stage receives T1 and the last one sends T2
+func Assemble[T1 any, T2 any](r *StageRegistry, specs ...StageSpec) (Stage[T1, T2], error) {
+	stage, in, out, err := r.Assemble(specs...)
+	if err != nil {
+		return nil, err
+	}
+	if len(specs) == 0 {
+		in, out = reflect.TypeFor[T1](), reflect.TypeFor[T1]()
+	}
+	if in != reflect.TypeFor[T1]() || out != reflect.TypeFor[T2]() {
+		return nil, fmt.Errorf("%w: pipeline takes %v to %v, not %v to %v", ErrStageType, in, out, reflect.TypeFor[T1](), reflect.TypeFor[T2]())
+	}
+	return typeStage[T1, T2](stage), nil
+}
+
+// Wraps a typed stage as one that receives and sends untyped values
+func eraseStage[T1 any, T2 any](stage Stage[T1, T2]) Stage[any, any] {
+	return func(source <-chan any) <-chan any {
+		return Map(stage(Map(source, func(s any) T1 { return s.(T1) })), func(s T2) any { return s })
+	}
+}
+
+// Wraps an untyped stage as a typed one, asserting the type of its results
+func typeStage[T1 any, T2 any](stage Stage[any, any]) Stage[T1, T2] {
+	return func(source <-chan T1) <-chan T2 {
+		return Map(stage(Map(source, func(s T1) any { return s })), func(s any) T2 { return s.(T2) })
+	}
+}
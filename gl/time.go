@@ -0,0 +1,505 @@
+package gl
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A value along with the time of the event it describes
+type Timestamped[T any] struct {
+	Value T
+	Time  time.Time
+}
+
+// Sends each value received on a channel on a new channel,
+// stamped with the time at which it was received
+func Timestamp[T any](source <-chan T) <-chan Timestamped[T] {
+	return Map(source, func(s T) Timestamped[T] { return Timestamped[T]{Value: s, Time: time.Now()} })
+}
+
+// Forwards the timestamped values received on a channel,
+// discarding those whose timestamp is already more than maxAge old when they are received,
+// so that latency-sensitive consumers are not flooded with a stale backlog.
+// Also returns a counter of the values discarded so far.
+func DropOlderThan[T any](source <-chan Timestamped[T], maxAge time.Duration, options ...Option) (<-chan Timestamped[T], *atomic.Int64) {
+	dropped := new(atomic.Int64)
+	if source == nil {
+		return nil, dropped
+	}
+	output, ctx, done := newConfiguredStage[Timestamped[T]](context.Background(), configure(options), source)
+	go func() {
+		defer done()
+		for s := range source {
+			if time.Since(s.Time) > maxAge {
+				dropped.Add(1)
+				continue
+			}
+			if !send(ctx, output, s) {
+				return
+			}
+		}
+	}()
+	return output, dropped
+}
+
+// Forwards the values received on a channel,
+// sending at most n = perInterval of them in any span of the given interval
+// and holding back the rest until they can be sent.
+// A perInterval below one is treated as one.
+func Throttle[T any](source <-chan T, perInterval int, interval time.Duration, options ...Option) <-chan T {
+	if source == nil {
+		return nil
+	}
+	output, ctx, done := newConfiguredStage[T](context.Background(), configure(options), source)
+	go func() {
+		defer done()
+		sent := newRing[time.Time](max(perInterval, 1))
+		for s := range source {
+			if oldest, full := sent.oldestIfFull(); full && !sleep(ctx, time.Until(oldest.Add(interval))) {
+				return
+			}
+			if !send(ctx, output, s) {
+				return
+			}
+			sent.push(time.Now())
+		}
+	}()
+	return output
+}
+
+// Waits for the given duration unless the context is done first.
+// Reports whether the full duration passed.
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// The period used for a ticker asked for a period of zero or less,
+// which time.NewTicker would panic on
+const minTickPeriod = time.Millisecond
+
+// Returns the given ticker period, or minTickPeriod if it is not positive
+func tickPeriod(d time.Duration) time.Duration {
+	if d <= 0 {
+		return minTickPeriod
+	}
+	return d
+}
+
+// Forwards a value received on a channel only once no newer value
+// has arrived for the given quiet period, so that each burst of values
+// is collapsed into its last value.
+// If the source closes during a burst, that burst's last value is sent straight away.
+func Debounce[T any](source <-chan T, quiet time.Duration, options ...Option) <-chan T {
+	if source == nil {
+		return nil
+	}
+	output, ctx, done := newConfiguredStage[T](context.Background(), configure(options), source)
+	go func() {
+		defer done()
+		timer := time.NewTimer(quiet)
+		timer.Stop()
+		defer timer.Stop()
+		var pending T
+		var fire <-chan time.Time // nil while no value is pending
+		for {
+			select {
+			case s, ok := <-source:
+				if !ok {
+					if fire != nil {
+						send(ctx, output, pending)
+					}
+					return
+				}
+				pending = s
+				timer.Reset(quiet)
+				fire = timer.C
+			case <-fire:
+				fire = nil
+				if !send(ctx, output, pending) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// Forwards, at every tick of the given period, the most recent value received
+// on a channel since the previous tick, dropping the values it replaced.
+// Nothing is sent for a tick if no value arrived since the previous one.
+// If the source closes with a value not yet sent, that value is sent straight away.
+// A period of zero or less is taken as one millisecond.
+func Sample[T any](source <-chan T, every time.Duration, options ...Option) <-chan T {
+	if source == nil {
+		return nil
+	}
+	every = tickPeriod(every)
+	output, ctx, done := newConfiguredStage[T](context.Background(), configure(options), source)
+	go func() {
+		defer done()
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		var latest T
+		fresh := false
+		for {
+			select {
+			case s, ok := <-source:
+				if !ok {
+					if fresh {
+						send(ctx, output, latest)
+					}
+					return
+				}
+				latest, fresh = s, true
+			case <-ticker.C:
+				if fresh {
+					fresh = false
+					if !send(ctx, output, latest) {
+						return
+					}
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// Collects the values received on a channel and, at every tick of the given period,
+// sends everything received since the previous tick as one slice.
+// Each value appears in exactly one snapshot, and each snapshot is a fresh slice
+// that the operator does not touch again once sent.
+// Nothing is sent for a tick if no value arrived since the previous one.
+// When the source closes, whatever was received since the last tick is sent straight away.
+// A period of zero or less is taken as one millisecond.
+// With WithMemoryBudget, the values of the snapshot being collected are held within the budget.
+func SnapshotEvery[T any](source <-chan T, every time.Duration, options ...Option) <-chan []T {
+	if source == nil {
+		return nil
+	}
+	every = tickPeriod(every)
+	config := configure(options)
+	output, ctx, done := newConfiguredStage[[]T](context.Background(), config, source)
+	go func() {
+		defer done()
+		memory := config.memoryAccount()
+		defer memory.releaseAll()
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		var pending []T
+		for {
+			select {
+			case s, ok := <-source:
+				if !ok {
+					if len(pending) > 0 {
+						send(ctx, output, pending)
+					}
+					return
+				}
+				if !memory.hold(s) {
+					return
+				}
+				pending = append(pending, s)
+			case <-ticker.C:
+				if len(pending) > 0 {
+					if !send(ctx, output, pending) {
+						return
+					}
+					releaseEach(memory, pending)
+					pending = nil
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// Pairs up values from two channels whose timestamps are within the given tolerance
+// of each other, rather than by position as Zip does, and sends the pairs on a new channel.
+// Both channels must send their values in timestamp order.
+// A value that cannot be matched (the other channel's next value is too far ahead of it)
+// is passed to the matching unmatched function, if not nil, and otherwise dropped.
+// Like Zip, it stops as soon as either channel closes;
+// a value already received from the other channel is then reported unmatched.
+func AlignByTime[T1 any, T2 any](a <-chan Timestamped[T1], b <-chan Timestamped[T2], tolerance time.Duration, unmatchedA func(Timestamped[T1]), unmatchedB func(Timestamped[T2]), options ...Option) <-chan Pair[Timestamped[T1], Timestamped[T2]] {
+	if a == nil || b == nil {
+		return nil
+	}
+	output, ctx, done := newConfiguredStage[Pair[Timestamped[T1], Timestamped[T2]]](context.Background(), configure(options), a, b)
+	go func() {
+		defer done()
+		x, hasX := receive(ctx, a)
+		y, hasY := receive(ctx, b)
+		defer func() {
+			if hasX && unmatchedA != nil {
+				unmatchedA(x)
+			}
+			if hasY && unmatchedB != nil {
+				unmatchedB(y)
+			}
+		}()
+		for hasX && hasY {
+			gap := x.Time.Sub(y.Time)
+			switch {
+			case gap.Abs() <= tolerance:
+				if !send(ctx, output, Pair[Timestamped[T1], Timestamped[T2]]{First: x, Second: y}) {
+					return
+				}
+				x, hasX = receive(ctx, a)
+				y, hasY = receive(ctx, b)
+			case gap < 0:
+				if unmatchedA != nil {
+					unmatchedA(x)
+				}
+				x, hasX = receive(ctx, a)
+			default:
+				if unmatchedB != nil {
+					unmatchedB(y)
+				}
+				y, hasY = receive(ctx, b)
+			}
+		}
+	}()
+	return output
+}
+
+// Sent by Timeout when no value arrives within the allowed gap
+var ErrTimeout = errors.New("gl: timed out waiting for the next value")
+
+// Forwards each value received on a channel as a successful Result.
+// If no value arrives within the given gap of the previous one
+// (or of the start, for the first value), a failed Result holding ErrTimeout
+// is sent instead and the stream ends, stopping the source.
+func Timeout[T any](source <-chan T, gap time.Duration, options ...Option) <-chan Result[T] {
+	if source == nil {
+		return nil
+	}
+	output, ctx, done := newConfiguredStage[Result[T]](context.Background(), configure(options), source)
+	go func() {
+		defer done()
+		timer := time.NewTimer(gap)
+		defer timer.Stop()
+		for {
+			select {
+			case s, ok := <-source:
+				if !ok {
+					return
+				}
+				if !send(ctx, output, Ok(s)) {
+					return
+				}
+				timer.Reset(gap)
+			case <-timer.C:
+				send(ctx, output, Fail[T](ErrTimeout))
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// Create a channel and send on it an incrementing counter, starting at zero,
+// once every period d. Like time.Ticker, ticks are dropped rather than queued
+// while the receiver is slow. The channel never closes on its own;
+// stop it with Stop, or use IntervalCtx and cancel the context.
+// A period of zero or less is taken as one millisecond.
+func Interval(d time.Duration, options ...Option) <-chan int {
+	return IntervalCtx(context.Background(), d, options...)
+}
+
+// Context-aware Interval
+func IntervalCtx(ctx context.Context, d time.Duration, options ...Option) <-chan int {
+	d = tickPeriod(d)
+	output, ctx, done := newConfiguredStage[int](ctx, configure(options))
+	go func() {
+		defer done()
+		ticker := time.NewTicker(d)
+		defer ticker.Stop()
+		for count := 0; ; count++ {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+			if !send(ctx, output, count) {
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// Create a channel, send on it the current time once d has passed, then close it
+func Timer(d time.Duration, options ...Option) <-chan time.Time {
+	return TimerCtx(context.Background(), d, options...)
+}
+
+// Context-aware Timer
+func TimerCtx(ctx context.Context, d time.Duration, options ...Option) <-chan time.Time {
+	output, ctx, done := newConfiguredStage[time.Time](ctx, configure(options))
+	go func() {
+		defer done()
+		if sleep(ctx, d) {
+			send(ctx, output, time.Now())
+		}
+	}()
+	return output
+}
+
+// Raised by AlertOnRate when a stream's rate of change crosses its threshold
+type Alert struct {
+	Time  time.Time // timestamp of the value at which the threshold was crossed
+	Value float64   // that value
+	Rate  float64   // the rate of change over the window, in units per second
+}
+
+// Computes the rate of change of timestamped values received on a channel,
+// in units per second, between the oldest and newest values within the given window,
+// and sends an Alert on a new channel each time its magnitude rises above the threshold.
+// No further alert is sent until the magnitude has fallen back to the threshold or below.
+// Values must be received in timestamp order.
+func AlertOnRate[T Number](source <-chan Timestamped[T], window time.Duration, threshold float64, options ...Option) <-chan Alert {
+	if source == nil {
+		return nil
+	}
+	output, ctx, done := newConfiguredStage[Alert](context.Background(), configure(options), source)
+	go func() {
+		defer done()
+		var recent []Timestamped[T]
+		alerting := false
+		for s := range source {
+			recent = append(recent, s)
+			drop := 0
+			for drop < len(recent)-1 && s.Time.Sub(recent[drop].Time) > window {
+				drop++
+			}
+			recent = recent[drop:]
+			oldest := recent[0]
+			elapsed := s.Time.Sub(oldest.Time).Seconds()
+			if elapsed <= 0 {
+				continue
+			}
+			rate := (float64(s.Value) - float64(oldest.Value)) / elapsed
+			if math.Abs(rate) <= threshold {
+				alerting = false
+				continue
+			}
+			if alerting {
+				continue
+			}
+			alerting = true
+			if !send(ctx, output, Alert{Time: s.Time, Value: float64(s.Value), Rate: rate}) {
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// Merges the timestamped values received on several channels into a new channel
+// in timestamp order, for feeds whose producers deliver each value within a bounded delay.
+// Each value is held back until the given lag has passed since its timestamp,
+// so that values from slower producers can still be slotted in ahead of it.
+// A value that arrives more than lag after its timestamp is sent as soon as it arrives,
+// which may put it out of order. Nil sources are ignored.
+// Once every source has closed, the values still held back are sent in order straight away.
+// With WithMemoryBudget, the values held back are held within the budget.
+func CollectSortedWithin[T any](sources []<-chan Timestamped[T], lag time.Duration, options ...Option) <-chan Timestamped[T] {
+	upstream := make([]any, len(sources))
+	for i, source := range sources {
+		upstream[i] = source
+	}
+	config := configure(options)
+	output, ctx, done := newConfiguredStage[Timestamped[T]](context.Background(), config, upstream...)
+	arrivals := make(chan Timestamped[T])
+	var wg sync.WaitGroup
+	for _, source := range sources {
+		if source == nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				s, ok := receive(ctx, source)
+				if !ok || !send(ctx, arrivals, s) {
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(arrivals)
+	}()
+	go func() {
+		defer done()
+		memory := config.memoryAccount()
+		defer memory.releaseAll()
+		held := &priorityHeap[Timestamped[T]]{less: func(a, b Timestamped[T]) bool { return a.Time.Before(b.Time) }}
+		// Sends the earliest value held back
+		release := func() bool {
+			s := heap.Pop(held).(Timestamped[T])
+			if !send(ctx, output, s) {
+				return false
+			}
+			memory.release(s)
+			return true
+		}
+		timer := time.NewTimer(lag)
+		defer timer.Stop()
+		for {
+			for held.Len() > 0 && !time.Now().Before(held.values[0].Time.Add(lag)) {
+				if !release() {
+					return
+				}
+			}
+			var wake <-chan time.Time
+			if held.Len() > 0 {
+				timer.Reset(time.Until(held.values[0].Time.Add(lag)))
+				wake = timer.C
+			}
+			select {
+			case s, ok := <-arrivals:
+				if !ok {
+					for held.Len() > 0 {
+						if !release() {
+							return
+						}
+					}
+					return
+				}
+				if !memory.hold(s) {
+					return
+				}
+				heap.Push(held, s)
+			case <-wake:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return output
+}
@@ -0,0 +1,186 @@
+package gl
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// A Hop records the time at which a traced element passed through a named stage
+type Hop struct {
+	Stage string
+	At    time.Time
+}
+
+// A Traced value carries an element through a pipeline
+// along with a lineage ID and the hops it has made so far
+type Traced[T any] struct {
+	ID    uint64
+	Value T
+	Hops  []Hop
+}
+
+// Source of lineage IDs; shared by all traced pipelines
+var nextTraceID atomic.Uint64
+
+// Wraps each element received on a channel in a Traced value
+// with a fresh lineage ID and an initial hop for the given stage name,
+// and sends the result on a new channel.
+func Trace[T any](source <-chan T, stage string, options ...Option) <-chan Traced[T] {
+	if source == nil {
+		return nil
+	}
+	output, ctx, done := newConfiguredStage[Traced[T]](context.Background(), configure(options), source)
+	go func() {
+		defer done()
+		for s := range source {
+			traced := Traced[T]{
+				ID:    nextTraceID.Add(1),
+				Value: s,
+				Hops:  []Hop{{Stage: stage, At: time.Now()}},
+			}
+			if !send(ctx, output, traced) {
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// Like Map, but operates on traced elements:
+// the mapper is applied to the wrapped value, the lineage ID is kept,
+// and a hop for the given stage name is recorded.
+func TraceMap[T1 any, T2 any](source <-chan Traced[T1], stage string, mapper func(T1) T2, options ...Option) <-chan Traced[T2] {
+	if source == nil {
+		return nil
+	}
+	output, ctx, done := newConfiguredStage[Traced[T2]](context.Background(), configure(options), source)
+	go func() {
+		defer done()
+		for s := range source {
+			traced := Traced[T2]{
+				ID:    s.ID,
+				Value: mapper(s.Value),
+				Hops:  append(slices.Clip(s.Hops), Hop{Stage: stage, At: time.Now()}),
+			}
+			if !send(ctx, output, traced) {
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// Like Filter, but operates on traced elements.
+// Elements that pass the predicate get a hop for the given stage name.
+// If dropped is not nil, it is called with each element the predicate rejects,
+// so callers can see which lineage IDs were lost and where.
+func TraceFilter[T any](source <-chan Traced[T], stage string, predicate func(T) bool, dropped func(Traced[T]), options ...Option) <-chan Traced[T] {
+	if source == nil {
+		return nil
+	}
+	output, ctx, done := newConfiguredStage[Traced[T]](context.Background(), configure(options), source)
+	go func() {
+		defer done()
+		for s := range source {
+			if predicate(s.Value) {
+				s.Hops = append(slices.Clip(s.Hops), Hop{Stage: stage, At: time.Now()})
+				if !send(ctx, output, s) {
+					return
+				}
+			} else if dropped != nil {
+				dropped(s)
+			}
+		}
+	}()
+	return output
+}
+
+// Like Zip, but operates on traced elements.
+// The result keeps the lineage ID of the element from xs;
+// its hops are those of both inputs followed by a hop for the given stage name.
+func TraceZip[T1 any, T2 any, T3 any](xs <-chan Traced[T1], ys <-chan Traced[T2], stage string, mapper func(T1, T2) T3, options ...Option) <-chan Traced[T3] {
+	return Zip(xs, ys, func(x Traced[T1], y Traced[T2]) Traced[T3] {
+		return Traced[T3]{ID: x.ID, Value: mapper(x.Value, y.Value), Hops: joinHops(x.Hops, y.Hops, stage)}
+	}, options...)
+}
+
+// Like MergeJoin, but operates on traced elements, which must each be sorted by key.
+// Each result keeps the lineage ID of its left element;
+// its hops are those of both inputs followed by a hop for the given stage name.
+func TraceJoin[L any, R any, K cmp.Ordered, O any](left <-chan Traced[L], right <-chan Traced[R], stage string, leftKey func(L) K, rightKey func(R) K, result func(L, R) O, options ...Option) <-chan Traced[O] {
+	return MergeJoin(left, right,
+		func(l Traced[L]) K { return leftKey(l.Value) },
+		func(r Traced[R]) K { return rightKey(r.Value) },
+		func(l Traced[L], r Traced[R]) Traced[O] {
+			return Traced[O]{ID: l.ID, Value: result(l.Value, r.Value), Hops: joinHops(l.Hops, r.Hops, stage)}
+		}, options...)
+}
+
+// Returns the hops of two joined elements followed by a hop for the given stage name,
+// in a new slice so that neither element's hops are touched
+func joinHops(x, y []Hop, stage string) []Hop {
+	hops := make([]Hop, 0, len(x)+len(y)+1)
+	hops = append(hops, x...)
+	hops = append(hops, y...)
+	return append(hops, Hop{Stage: stage, At: time.Now()})
+}
+
+// Strips the tracing information from each element
+// and sends the bare values on a new channel
+func Untrace[T any](source <-chan Traced[T], options ...Option) <-chan T {
+	return Map(source, func(t Traced[T]) T { return t.Value }, options...)
+}
+
+// The end-to-end latency distribution of a traced pipeline,
+// measured from each element's first hop to the time it was received
+// by TraceLatencies
+type LatencyReport struct {
+	Count int
+	Min   time.Duration
+	Max   time.Duration
+	Mean  time.Duration
+	P50   time.Duration
+	P95   time.Duration
+	P99   time.Duration
+	// The latencies of the elements received with each lineage ID, in the order received.
+	// An ID can appear more than once, as when TraceJoin or TraceZip fan an element out.
+	PerElement map[uint64][]time.Duration
+}
+
+// Listens on a channel of traced elements until it is closed
+// and returns the distribution of end-to-end latencies
+func TraceLatencies[T any](source <-chan Traced[T]) LatencyReport {
+	report := LatencyReport{PerElement: make(map[uint64][]time.Duration)}
+	var latencies []time.Duration
+	var total time.Duration
+	for s := range source {
+		var latency time.Duration
+		if len(s.Hops) > 0 {
+			latency = time.Since(s.Hops[0].At)
+		}
+		report.PerElement[s.ID] = append(report.PerElement[s.ID], latency)
+		latencies = append(latencies, latency)
+		total += latency
+	}
+	report.Count = len(latencies)
+	if report.Count == 0 {
+		return report
+	}
+	slices.Sort(latencies)
+	report.Min = latencies[0]
+	report.Max = latencies[len(latencies)-1]
+	report.Mean = total / time.Duration(report.Count)
+	report.P50 = percentile(latencies, 0.50)
+	report.P95 = percentile(latencies, 0.95)
+	report.P99 = percentile(latencies, 0.99)
+	return report
+}
+
+// Returns the element at the given quantile of an already-sorted slice
+func percentile[T any](sorted []T, q float64) T {
+	index := int(q * float64(len(sorted)-1))
+	return sorted[index]
+}
@@ -0,0 +1,62 @@
+package gl
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+)
+
+// A file or directory found by WalkDir, along with its path
+type PathEntry struct {
+	Path string
+	fs.DirEntry
+}
+
+// Walks the file tree rooted at root in lexical order, as filepath.WalkDir does,
+// and sends each file and directory found (root included) on a new channel as a successful Result,
+// so that a scan can be filtered, mapped and processed in parallel.
+// An error reading a directory is sent as a failed Result and the walk carries on without it.
+// Stopping the channel stops the walk.
+func WalkDir(root string, options ...Option) <-chan Result[PathEntry] {
+	return WalkDirCtx(context.Background(), root, options...)
+}
+
+// Context-aware WalkDir
+func WalkDirCtx(ctx context.Context, root string, options ...Option) <-chan Result[PathEntry] {
+	return walk(ctx, func(visit fs.WalkDirFunc) error {
+		return filepath.WalkDir(root, visit)
+	}, options)
+}
+
+// Like WalkDir, but walks the given file system, as fs.WalkDir does
+func WalkFS(fsys fs.FS, root string, options ...Option) <-chan Result[PathEntry] {
+	return WalkFSCtx(context.Background(), fsys, root, options...)
+}
+
+// Context-aware WalkFS
+func WalkFSCtx(ctx context.Context, fsys fs.FS, root string, options ...Option) <-chan Result[PathEntry] {
+	return walk(ctx, func(visit fs.WalkDirFunc) error {
+		return fs.WalkDir(fsys, root, visit)
+	}, options)
+}
+
+// Runs the given walk in a new stage, sending what it visits
+func walk(ctx context.Context, run func(fs.WalkDirFunc) error, options []Option) <-chan Result[PathEntry] {
+	output, ctx, done := newConfiguredStage[Result[PathEntry]](ctx, configure(options))
+	go func() {
+		defer done()
+		run(func(path string, entry fs.DirEntry, err error) error {
+			result := Ok(PathEntry{Path: path, DirEntry: entry})
+			if err != nil {
+				result = Fail[PathEntry](fmt.Errorf("gl: walking directory: %w", err))
+			}
+			if !send(ctx, output, result) {
+				return fs.SkipAll
+			}
+			// Returning nil after an error skips the directory that could not be read
+			return nil
+		})
+	}()
+	return output
+}
@@ -0,0 +1,82 @@
+package gl
+
+import (
+	"context"
+	"sync"
+)
+
+// Per-element contexts.
+// In a server, each element of a stream may belong to a different request,
+// with its own deadline, trace, or tenant. A WithCtx value carries that
+// context alongside the element, and the operators below hand it to the
+// caller's functions and drop elements whose context is already done.
+
+// An element together with the context of the request it belongs to
+type WithCtx[T any] struct {
+	Ctx   context.Context
+	Value T
+}
+
+// Attaches to each element received on a channel the context returned for it by ctxOf,
+// and sends the result on a new channel
+func AttachCtx[T any](source <-chan T, ctxOf func(T) context.Context) <-chan WithCtx[T] {
+	return Map(source, func(s T) WithCtx[T] { return WithCtx[T]{Ctx: ctxOf(s), Value: s} })
+}
+
+// Strips the context from each element and sends the bare values on a new channel
+func DetachCtx[T any](source <-chan WithCtx[T]) <-chan T {
+	return Map(source, func(s WithCtx[T]) T { return s.Value })
+}
+
+// Reports whether an element's context is still live
+func live[T any](s WithCtx[T]) bool {
+	return s.Ctx.Err() == nil
+}
+
+// Like Map, but the mapper is given each element's context,
+// which is kept with the result.
+// Elements whose context is done are dropped without calling the mapper.
+func MapWithCtx[T1 any, T2 any](source <-chan WithCtx[T1], mapper func(context.Context, T1) T2) <-chan WithCtx[T2] {
+	return FuseMap(Fuse(source).Filter(live[T1]), func(s WithCtx[T1]) WithCtx[T2] {
+		return WithCtx[T2]{Ctx: s.Ctx, Value: mapper(s.Ctx, s.Value)}
+	}).Chan()
+}
+
+// Like Filter, but the predicate is given each element's context.
+// Elements whose context is done are dropped without calling the predicate.
+func FilterWithCtx[T any](source <-chan WithCtx[T], predicate func(context.Context, T) bool) <-chan WithCtx[T] {
+	return Fuse(source).Filter(func(s WithCtx[T]) bool {
+		return live(s) && predicate(s.Ctx, s.Value)
+	}).Chan()
+}
+
+// Calls the given action with each element received on a channel and its context,
+// on the given number of worker goroutines, and returns once the channel is closed
+// and every action has returned.
+// Elements whose context is done by the time a worker picks them up are skipped.
+// Pass WithAutoParallel to follow GOMAXPROCS.
+func ForEachParallelWithCtx[T any](source <-chan WithCtx[T], action func(context.Context, T), workers int, options ...Option) {
+	if source == nil {
+		return
+	}
+	config := configure(options)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var finished sync.WaitGroup
+	finished.Add(1)
+	startWorkerPool(ctx, config, workerLimit(workers, config), func(w int, active func() bool) {
+		for active() {
+			s, ok := receive(ctx, source)
+			if !ok {
+				return
+			}
+			if live(s) {
+				action(s.Ctx, s.Value)
+			}
+		}
+	}, finished.Done, func() {
+		cancel()
+		Stop(source)
+	})
+	finished.Wait()
+}
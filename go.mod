@@ -1,3 +1,3 @@
 module golinq
 
-go 1.23.4
+go 1.24